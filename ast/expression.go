@@ -2,21 +2,53 @@ package ast
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/corani/bantamgo/lexer"
 )
 
+// Node is anything in the syntax tree that covers part of the source.
+type Node interface {
+	Span() lexer.Span
+	// Comments returns the comments attached to the node, which a printer
+	// needs to reproduce them.
+	Comments() *Comments
+}
+
+// Comments are the comments before and after a node. Comments inside a node
+// are attached to its children.
+type Comments struct {
+	Leading  []lexer.Trivia
+	Trailing []lexer.Trivia
+}
+
 type Expression interface {
+	Node
 	Visit(v Visitor)
 }
 
+// node holds the source span and comments shared by all nodes.
+type node struct {
+	span     lexer.Span
+	comments Comments
+}
+
+func (n node) Span() lexer.Span {
+	return n.span
+}
+
+func (n *node) Comments() *Comments {
+	return &n.comments
+}
+
 // ----- BLOCK EXPRESSION -----
 
-func BlockExpression(expressions []Expression) *BlockExpressionNode {
-	return &BlockExpressionNode{Expressions: expressions}
+func BlockExpression(span lexer.Span, expressions []Expression) *BlockExpressionNode {
+	return &BlockExpressionNode{node: node{span: span}, Expressions: expressions}
 }
 
 type BlockExpressionNode struct {
+	node
 	Expressions []Expression
 }
 
@@ -26,11 +58,12 @@ func (e *BlockExpressionNode) Visit(v Visitor) {
 
 // ----- NAME EXPRESSION -----
 
-func NameExpression(name string) *NameExpressionNode {
-	return &NameExpressionNode{Name: name}
+func NameExpression(span lexer.Span, name string) *NameExpressionNode {
+	return &NameExpressionNode{node: node{span: span}, Name: name}
 }
 
 type NameExpressionNode struct {
+	node
 	Name string
 }
 
@@ -40,31 +73,82 @@ func (e *NameExpressionNode) Visit(v Visitor) {
 
 // ----- NUMBER EXPRESSION -----
 
-func NumberExpression(text string) (*NumberExpressionNode, error) {
-	val, err := strconv.ParseFloat(text, 64)
+func NumberExpression(span lexer.Span, text string) (*NumberExpressionNode, error) {
+	val, err := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
 	if err != nil {
 		return nil, err
 	}
 
-	return &NumberExpressionNode{Text: text, Value: val}, nil
+	return &NumberExpressionNode{node: node{span: span}, Text: text, Value: val}, nil
 }
 
 type NumberExpressionNode struct {
+	node
 	Text  string
 	Value float64
 }
 
 func (e *NumberExpressionNode) Visit(v Visitor) {
-	v.VisitNumber(e.Value)
+	v.VisitNumber(e.Text, e.Value)
+}
+
+// ----- INTEGER EXPRESSION -----
+
+func IntegerExpression(span lexer.Span, text string) (*IntegerExpressionNode, error) {
+	base := 10
+
+	// Let ParseInt figure out the base from the "0x", "0b" or "0o" prefix.
+	if lexer.HasBasePrefix(text) {
+		base = 0
+	}
+
+	val, err := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), base, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntegerExpressionNode{node: node{span: span}, Text: text, Value: val}, nil
+}
+
+type IntegerExpressionNode struct {
+	node
+	Text  string
+	Value int64
+}
+
+func (e *IntegerExpressionNode) Visit(v Visitor) {
+	v.VisitInteger(e.Text, e.Value)
+}
+
+// ----- STRING EXPRESSION -----
+
+func StringExpression(span lexer.Span, text string) (*StringExpressionNode, error) {
+	val, err := lexer.Unquote(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StringExpressionNode{node: node{span: span}, Text: text, Value: val}, nil
+}
+
+type StringExpressionNode struct {
+	node
+	Text  string
+	Value string
+}
+
+func (e *StringExpressionNode) Visit(v Visitor) {
+	v.VisitString(e.Value)
 }
 
 // ----- ASSIGN EXPRESSION -----
 
-func AssignExpression(name string, value Expression) *AssignExpressionNode {
-	return &AssignExpressionNode{Name: name, Right: value}
+func AssignExpression(span lexer.Span, name string, value Expression) *AssignExpressionNode {
+	return &AssignExpressionNode{node: node{span: span}, Name: name, Right: value}
 }
 
 type AssignExpressionNode struct {
+	node
 	Name  string
 	Right Expression
 }
@@ -75,11 +159,12 @@ func (e *AssignExpressionNode) Visit(v Visitor) {
 
 // ----- CONDITIONAL EXPRESSION -----
 
-func ConditionalExpression(condition, thenBranch, elseBranch Expression) *ConditionalExpressionNode {
-	return &ConditionalExpressionNode{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
+func ConditionalExpression(span lexer.Span, condition, thenBranch, elseBranch Expression) *ConditionalExpressionNode {
+	return &ConditionalExpressionNode{node: node{span: span}, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
 }
 
 type ConditionalExpressionNode struct {
+	node
 	Condition  Expression
 	ThenBranch Expression
 	ElseBranch Expression
@@ -91,26 +176,36 @@ func (e *ConditionalExpressionNode) Visit(v Visitor) {
 
 // ----- CALL EXPRESSION -----
 
-func CallExpression(callee Expression, args []Expression) *CallExpressionNode {
-	return &CallExpressionNode{Callee: callee, Args: args}
+func CallExpression(span lexer.Span, callee Expression, args []Expression, named []NamedArgument) *CallExpressionNode {
+	return &CallExpressionNode{node: node{span: span}, Callee: callee, Args: args, Named: named}
+}
+
+// NamedArgument is an argument that is passed by the name of the parameter,
+// as in "round(x, digits: 2)".
+type NamedArgument struct {
+	Name  string
+	Value Expression
 }
 
 type CallExpressionNode struct {
+	node
 	Callee Expression
 	Args   []Expression
+	Named  []NamedArgument
 }
 
 func (e *CallExpressionNode) Visit(v Visitor) {
-	v.VisitCall(e.Callee, e.Args)
+	v.VisitCall(e.Callee, e.Args, e.Named)
 }
 
 // ----- PREFIX EXPRESSION -----
 
-func PrefixExpression(operator lexer.TokenType, right Expression) *PrefixExpressionNode {
-	return &PrefixExpressionNode{Operator: operator, Right: right}
+func PrefixExpression(span lexer.Span, operator lexer.TokenType, right Expression) *PrefixExpressionNode {
+	return &PrefixExpressionNode{node: node{span: span}, Operator: operator, Right: right}
 }
 
 type PrefixExpressionNode struct {
+	node
 	Operator lexer.TokenType
 	Right    Expression
 }
@@ -121,11 +216,12 @@ func (e *PrefixExpressionNode) Visit(v Visitor) {
 
 // ----- POSTFIX EXPRESSION -----
 
-func PostfixExpression(left Expression, operator lexer.TokenType) *PostfixExpressionNode {
-	return &PostfixExpressionNode{Operator: operator, Left: left}
+func PostfixExpression(span lexer.Span, left Expression, operator lexer.TokenType) *PostfixExpressionNode {
+	return &PostfixExpressionNode{node: node{span: span}, Operator: operator, Left: left}
 }
 
 type PostfixExpressionNode struct {
+	node
 	Operator lexer.TokenType
 	Left     Expression
 }
@@ -136,11 +232,12 @@ func (e *PostfixExpressionNode) Visit(v Visitor) {
 
 // ----- INFIX EXPRESSION -----
 
-func InfixExpression(left Expression, operator lexer.TokenType, right Expression) *InfixExpressionNode {
-	return &InfixExpressionNode{Left: left, Operator: operator, Right: right}
+func InfixExpression(span lexer.Span, left Expression, operator lexer.TokenType, right Expression) *InfixExpressionNode {
+	return &InfixExpressionNode{node: node{span: span}, Left: left, Operator: operator, Right: right}
 }
 
 type InfixExpressionNode struct {
+	node
 	Left     Expression
 	Operator lexer.TokenType
 	Right    Expression
@@ -149,3 +246,295 @@ type InfixExpressionNode struct {
 func (e *InfixExpressionNode) Visit(v Visitor) {
 	v.VisitInfix(e.Left, e.Operator, e.Right)
 }
+
+// ----- SCRIPT INFIX EXPRESSION -----
+
+// ScriptInfixExpression applies an infix operator declared in the script,
+// which is known by its spelling rather than a token type.
+func ScriptInfixExpression(span lexer.Span, left Expression, operator string, right Expression) *ScriptInfixExpressionNode {
+	return &ScriptInfixExpressionNode{node: node{span: span}, Left: left, Operator: operator, Right: right}
+}
+
+type ScriptInfixExpressionNode struct {
+	node
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (e *ScriptInfixExpressionNode) Visit(v Visitor) {
+	v.VisitScriptInfix(e.Left, e.Operator, e.Right)
+}
+
+// ----- LOGICAL EXPRESSION -----
+
+// LogicalExpression is a short-circuiting "&&" or "||". It's kept separate
+// from InfixExpression, as the right-hand side isn't always evaluated.
+func LogicalExpression(span lexer.Span, left Expression, operator lexer.TokenType, right Expression) *LogicalExpressionNode {
+	return &LogicalExpressionNode{node: node{span: span}, Left: left, Operator: operator, Right: right}
+}
+
+type LogicalExpressionNode struct {
+	node
+	Left     Expression
+	Operator lexer.TokenType
+	Right    Expression
+}
+
+func (e *LogicalExpressionNode) Visit(v Visitor) {
+	v.VisitLogical(e.Left, e.Operator, e.Right)
+}
+
+// ----- LAMBDA EXPRESSION -----
+
+func LambdaExpression(span lexer.Span, params []Parameter, body Expression) *LambdaExpressionNode {
+	return &LambdaExpressionNode{node: node{span: span}, Params: params, Body: body}
+}
+
+// Parameter is a parameter of a lambda. Default is nil if the parameter has
+// no default value.
+type Parameter struct {
+	Name    string
+	Default Expression
+}
+
+type LambdaExpressionNode struct {
+	node
+	Params []Parameter
+	Body   Expression
+}
+
+func (e *LambdaExpressionNode) Visit(v Visitor) {
+	v.VisitLambda(e.Params, e.Body)
+}
+
+// ----- OPERATOR EXPRESSION -----
+
+// OperatorExpression declares an infix operator, implemented by the function it
+// evaluates to.
+func OperatorExpression(span lexer.Span, operator string, precedence int, rightAssoc bool, function Expression) *OperatorExpressionNode {
+	return &OperatorExpressionNode{
+		node:       node{span: span},
+		Operator:   operator,
+		Precedence: precedence,
+		RightAssoc: rightAssoc,
+		Function:   function,
+	}
+}
+
+type OperatorExpressionNode struct {
+	node
+	Operator   string
+	Precedence int
+	RightAssoc bool
+	Function   Expression
+}
+
+func (e *OperatorExpressionNode) Visit(v Visitor) {
+	v.VisitOperator(e.Operator, e.Precedence, e.RightAssoc, e.Function)
+}
+
+// ----- IF EXPRESSION -----
+
+// IfExpression evaluates to the branch selected by the condition. ElseBranch is
+// nil if there's no "else".
+func IfExpression(span lexer.Span, condition, thenBranch, elseBranch Expression) *IfExpressionNode {
+	return &IfExpressionNode{node: node{span: span}, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
+}
+
+type IfExpressionNode struct {
+	node
+	Condition  Expression
+	ThenBranch Expression
+	ElseBranch Expression
+}
+
+func (e *IfExpressionNode) Visit(v Visitor) {
+	v.VisitIf(e.Condition, e.ThenBranch, e.ElseBranch)
+}
+
+// ----- WHILE EXPRESSION -----
+
+func WhileExpression(span lexer.Span, condition, body Expression) *WhileExpressionNode {
+	return &WhileExpressionNode{node: node{span: span}, Condition: condition, Body: body}
+}
+
+type WhileExpressionNode struct {
+	node
+	Condition Expression
+	Body      Expression
+}
+
+func (e *WhileExpressionNode) Visit(v Visitor) {
+	v.VisitWhile(e.Condition, e.Body)
+}
+
+// ----- FOR EXPRESSION -----
+
+// ForExpression runs the body with Name bound to each int from Start up to,
+// but not including, End.
+func ForExpression(span lexer.Span, name string, start, end, body Expression) *ForExpressionNode {
+	return &ForExpressionNode{node: node{span: span}, Name: name, Start: start, End: end, Body: body}
+}
+
+type ForExpressionNode struct {
+	node
+	Name  string
+	Start Expression
+	End   Expression
+	Body  Expression
+}
+
+func (e *ForExpressionNode) Visit(v Visitor) {
+	v.VisitFor(e.Name, e.Start, e.End, e.Body)
+}
+
+// ----- BREAK EXPRESSION -----
+
+func BreakExpression(span lexer.Span) *BreakExpressionNode {
+	return &BreakExpressionNode{node: node{span: span}}
+}
+
+type BreakExpressionNode struct {
+	node
+}
+
+func (e *BreakExpressionNode) Visit(v Visitor) {
+	v.VisitBreak()
+}
+
+// ----- CONTINUE EXPRESSION -----
+
+func ContinueExpression(span lexer.Span) *ContinueExpressionNode {
+	return &ContinueExpressionNode{node: node{span: span}}
+}
+
+type ContinueExpressionNode struct {
+	node
+}
+
+func (e *ContinueExpressionNode) Visit(v Visitor) {
+	v.VisitContinue()
+}
+
+// ----- LIST EXPRESSION -----
+
+func ListExpression(span lexer.Span, elements []Expression) *ListExpressionNode {
+	return &ListExpressionNode{node: node{span: span}, Elements: elements}
+}
+
+type ListExpressionNode struct {
+	node
+	Elements []Expression
+}
+
+func (e *ListExpressionNode) Visit(v Visitor) {
+	v.VisitList(e.Elements)
+}
+
+// ----- INDEX EXPRESSION -----
+
+func IndexExpression(span lexer.Span, target, index Expression) *IndexExpressionNode {
+	return &IndexExpressionNode{node: node{span: span}, Target: target, Index: index}
+}
+
+type IndexExpressionNode struct {
+	node
+	Target Expression
+	Index  Expression
+}
+
+func (e *IndexExpressionNode) Visit(v Visitor) {
+	v.VisitIndex(e.Target, e.Index)
+}
+
+// ----- SLICE EXPRESSION -----
+
+// SliceExpression takes the elements from Start up to, but not including, End.
+// Start and End are nil if they're left out.
+func SliceExpression(span lexer.Span, target, start, end Expression) *SliceExpressionNode {
+	return &SliceExpressionNode{node: node{span: span}, Target: target, Start: start, End: end}
+}
+
+type SliceExpressionNode struct {
+	node
+	Target Expression
+	Start  Expression
+	End    Expression
+}
+
+func (e *SliceExpressionNode) Visit(v Visitor) {
+	v.VisitSlice(e.Target, e.Start, e.End)
+}
+
+// ----- RECORD EXPRESSION -----
+
+func RecordExpression(span lexer.Span, fields []Field) *RecordExpressionNode {
+	return &RecordExpressionNode{node: node{span: span}, Fields: fields}
+}
+
+// Field is a named value in a record literal.
+type Field struct {
+	Name  string
+	Value Expression
+}
+
+type RecordExpressionNode struct {
+	node
+	Fields []Field
+}
+
+func (e *RecordExpressionNode) Visit(v Visitor) {
+	v.VisitRecord(e.Fields)
+}
+
+// ----- MEMBER EXPRESSION -----
+
+func MemberExpression(span lexer.Span, target Expression, name string) *MemberExpressionNode {
+	return &MemberExpressionNode{node: node{span: span}, Target: target, Name: name}
+}
+
+type MemberExpressionNode struct {
+	node
+	Target Expression
+	Name   string
+}
+
+func (e *MemberExpressionNode) Visit(v Visitor) {
+	v.VisitMember(e.Target, e.Name)
+}
+
+// ----- TUPLE EXPRESSION -----
+
+// TupleExpression is a parenthesized list of values like "(a, b)", which
+// evaluates to a list.
+func TupleExpression(span lexer.Span, elements []Expression) *TupleExpressionNode {
+	return &TupleExpressionNode{node: node{span: span}, Elements: elements}
+}
+
+type TupleExpressionNode struct {
+	node
+	Elements []Expression
+}
+
+func (e *TupleExpressionNode) Visit(v Visitor) {
+	v.VisitTuple(e.Elements)
+}
+
+// ----- DESTRUCTURE EXPRESSION -----
+
+// DestructureExpression assigns the parts of a value to the names in a
+// pattern. Assigning to a single name is an AssignExpression.
+func DestructureExpression(span lexer.Span, pattern Pattern, right Expression) *DestructureExpressionNode {
+	return &DestructureExpressionNode{node: node{span: span}, Pattern: pattern, Right: right}
+}
+
+type DestructureExpressionNode struct {
+	node
+	Pattern Pattern
+	Right   Expression
+}
+
+func (e *DestructureExpressionNode) Visit(v Visitor) {
+	v.VisitDestructure(e.Pattern, e.Right)
+}
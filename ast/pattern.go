@@ -0,0 +1,83 @@
+package ast
+
+import "github.com/corani/bantamgo/lexer"
+
+// Pattern is the target of a destructuring assignment, like "(a, b)" in
+// "(a, b) = (b, a)".
+type Pattern interface {
+	Node
+	Visit(v PatternVisitor)
+}
+
+type PatternVisitor interface {
+	VisitNamePattern(name string)
+	VisitTuplePattern(elements []Pattern)
+	VisitListPattern(elements []Pattern)
+	VisitRecordPattern(fields []FieldPattern)
+}
+
+// ----- NAME PATTERN -----
+
+func NamePattern(span lexer.Span, name string) *NamePatternNode {
+	return &NamePatternNode{node: node{span: span}, Name: name}
+}
+
+type NamePatternNode struct {
+	node
+	Name string
+}
+
+func (p *NamePatternNode) Visit(v PatternVisitor) {
+	v.VisitNamePattern(p.Name)
+}
+
+// ----- TUPLE PATTERN -----
+
+func TuplePattern(span lexer.Span, elements []Pattern) *TuplePatternNode {
+	return &TuplePatternNode{node: node{span: span}, Elements: elements}
+}
+
+type TuplePatternNode struct {
+	node
+	Elements []Pattern
+}
+
+func (p *TuplePatternNode) Visit(v PatternVisitor) {
+	v.VisitTuplePattern(p.Elements)
+}
+
+// ----- LIST PATTERN -----
+
+func ListPattern(span lexer.Span, elements []Pattern) *ListPatternNode {
+	return &ListPatternNode{node: node{span: span}, Elements: elements}
+}
+
+type ListPatternNode struct {
+	node
+	Elements []Pattern
+}
+
+func (p *ListPatternNode) Visit(v PatternVisitor) {
+	v.VisitListPattern(p.Elements)
+}
+
+// ----- RECORD PATTERN -----
+
+func RecordPattern(span lexer.Span, fields []FieldPattern) *RecordPatternNode {
+	return &RecordPatternNode{node: node{span: span}, Fields: fields}
+}
+
+// FieldPattern matches the field Name of a record against Pattern.
+type FieldPattern struct {
+	Name    string
+	Pattern Pattern
+}
+
+type RecordPatternNode struct {
+	node
+	Fields []FieldPattern
+}
+
+func (p *RecordPatternNode) Visit(v PatternVisitor) {
+	v.VisitRecordPattern(p.Fields)
+}
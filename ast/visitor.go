@@ -5,11 +5,31 @@ import "github.com/corani/bantamgo/lexer"
 type Visitor interface {
 	VisitBlock(expressions []Expression)
 	VisitName(name string)
-	VisitNumber(value float64)
+	// VisitNumber and VisitInteger get the source text of the number, so
+	// printers can reproduce it, and its value.
+	VisitNumber(text string, value float64)
+	VisitInteger(text string, value int64)
+	VisitString(value string)
 	VisitAssign(name string, right Expression)
 	VisitConditional(condition, thenBranch, elseBranch Expression)
-	VisitCall(callee Expression, arguments []Expression)
+	VisitCall(callee Expression, arguments []Expression, named []NamedArgument)
 	VisitPrefix(operator lexer.TokenType, right Expression)
 	VisitPostfix(left Expression, operator lexer.TokenType)
 	VisitInfix(left Expression, operator lexer.TokenType, right Expression)
+	VisitScriptInfix(left Expression, operator string, right Expression)
+	VisitLogical(left Expression, operator lexer.TokenType, right Expression)
+	VisitLambda(params []Parameter, body Expression)
+	VisitOperator(operator string, precedence int, rightAssoc bool, function Expression)
+	VisitIf(condition, thenBranch, elseBranch Expression)
+	VisitWhile(condition, body Expression)
+	VisitFor(name string, start, end, body Expression)
+	VisitBreak()
+	VisitContinue()
+	VisitList(elements []Expression)
+	VisitIndex(target, index Expression)
+	VisitSlice(target, start, end Expression)
+	VisitRecord(fields []Field)
+	VisitMember(target Expression, name string)
+	VisitTuple(elements []Expression)
+	VisitDestructure(pattern Pattern, right Expression)
 }
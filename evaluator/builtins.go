@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// defineBuiltins defines the host functions that are available to every
+// script.
+func defineBuiltins(env *Environment) {
+	env.Define("pow", Function{
+		Params: []Parameter{{Name: "x"}, {Name: "y"}},
+		Fn: func(args []Value) (Value, error) {
+			x, err := argNumber(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			y, err := argNumber(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return Float(math.Pow(x, y)), nil
+		},
+	})
+
+	env.Define("len", Function{
+		Params: []Parameter{{Name: "value"}},
+		Fn: func(args []Value) (Value, error) {
+			switch arg := args[0].(type) {
+			case List:
+				return Int(len(arg)), nil
+			case String:
+				return Int(utf8.RuneCountInString(string(arg))), nil
+			default:
+				return nil, Errorf(ErrorKindType, "expected a list or a string, got %v", arg.Kind())
+			}
+		},
+	})
+
+	env.Define("abs", Function{
+		Params: []Parameter{{Name: "x"}},
+		Fn: func(args []Value) (Value, error) {
+			if i, ok := args[0].(Int); ok {
+				if i == math.MinInt64 {
+					return nil, Errorf(ErrorKindRange, "absolute value of %d overflows", i)
+				}
+
+				return max(i, -i), nil
+			}
+
+			x, err := argNumber(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return Float(math.Abs(x)), nil
+		},
+	})
+
+	env.Define("sqrt", Function{
+		Params: []Parameter{{Name: "x"}},
+		Fn: func(args []Value) (Value, error) {
+			x, err := argNumber(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			if x < 0 {
+				return nil, Errorf(ErrorKindRange, "square root of negative number %v", args[0])
+			}
+
+			return Float(math.Sqrt(x)), nil
+		},
+	})
+
+	// round rounds half away from zero, to the given number of decimals. Ints
+	// are returned as is.
+	env.Define("round", Function{
+		Params: []Parameter{{Name: "x"}, {Name: "digits", Default: Int(0)}},
+		Fn: func(args []Value) (Value, error) {
+			digits, ok := args[1].(Int)
+			if !ok {
+				return nil, Errorf(ErrorKindType, "expected an int, got %v", args[1].Kind())
+			}
+
+			if i, ok := args[0].(Int); ok {
+				return roundInt(i, digits)
+			}
+
+			x, err := argNumber(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			scale := math.Pow(10, float64(digits))
+
+			return Float(math.Round(x*scale) / scale), nil
+		},
+	})
+}
+
+// roundInt rounds x to the given number of digits like math.Round, so a
+// negative number of digits rounds to a multiple of a power of ten. Ints have
+// no fraction, so other digits leave x unchanged.
+func roundInt(x, digits Int) (Value, error) {
+	overflow := Errorf(ErrorKindRange, "rounding %d to %d digits overflows", x, digits)
+	scale := Int(1)
+
+	for n := digits; n < 0; n++ {
+		next, ok := mulInt(scale, 10)
+		if !ok {
+			// 10^19 doesn't fit in an int, so x rounds to 0, unless it
+			// rounds to 10^19 or -10^19.
+			if n == -1 && (x >= 5e18 || x <= -5e18) {
+				return nil, overflow
+			}
+
+			return Int(0), nil
+		}
+
+		scale = next
+	}
+
+	q, r := x/scale, x%scale
+
+	if 2*r >= scale {
+		q++
+	} else if 2*r <= -scale {
+		q--
+	}
+
+	result, ok := mulInt(q, scale)
+	if !ok {
+		return nil, overflow
+	}
+
+	return result, nil
+}
+
+// argNumber converts an argument to a float64, or returns a type error.
+func argNumber(arg Value) (float64, error) {
+	x, ok := toFloat(arg)
+	if !ok {
+		return 0, Errorf(ErrorKindType, "expected a number, got %v", arg.Kind())
+	}
+
+	return x, nil
+}
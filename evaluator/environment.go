@@ -0,0 +1,69 @@
+package evaluator
+
+// Environment is a scope that maps names to values. Scopes are chained: a
+// name that isn't bound in a scope is looked up in its parent.
+//
+// Assignment follows these rules:
+//   - if the name is already bound in the current scope, or in one of the
+//     enclosing scopes up to the nearest function scope, that binding is
+//     updated;
+//   - otherwise a new binding is created in the current scope, shadowing any
+//     binding with the same name further up the chain.
+//
+// The root environment holds the builtins and is never assigned to from a
+// script, so a script can shadow a builtin without replacing it. Function
+// scopes stop assignments from leaking into the scope the function was
+// defined in.
+type Environment struct {
+	parent   *Environment
+	symbols  map[string]Value
+	function bool
+}
+
+func NewEnvironment(parent *Environment) *Environment {
+	return &Environment{
+		parent:  parent,
+		symbols: make(map[string]Value),
+	}
+}
+
+// newFunctionEnvironment creates the scope for a function call.
+func newFunctionEnvironment(parent *Environment) *Environment {
+	result := NewEnvironment(parent)
+	result.function = true
+
+	return result
+}
+
+// Get looks up a name in this scope and its parents.
+func (e *Environment) Get(name string) (Value, bool) {
+	for scope := e; scope != nil; scope = scope.parent {
+		if val, ok := scope.symbols[name]; ok {
+			return val, true
+		}
+	}
+
+	return nil, false
+}
+
+// Define binds a name in this scope, regardless of any outer bindings.
+func (e *Environment) Define(name string, value Value) {
+	e.symbols[name] = value
+}
+
+// Set assigns to a name, following the rules described on Environment.
+func (e *Environment) Set(name string, value Value) {
+	for scope := e; scope.parent != nil; scope = scope.parent {
+		if _, ok := scope.symbols[name]; ok {
+			scope.symbols[name] = value
+
+			return
+		}
+
+		if scope.function {
+			break
+		}
+	}
+
+	e.Define(name, value)
+}
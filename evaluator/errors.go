@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/corani/bantamgo/lexer"
+)
+
+type ErrorKind int
+
+const (
+	// ErrorKindUndefined is returned when reading a name that isn't defined.
+	ErrorKindUndefined ErrorKind = iota
+	// ErrorKindType is returned when a value of the wrong kind is used.
+	ErrorKindType
+	// ErrorKindArity is returned when calling a function with the wrong number
+	// of arguments.
+	ErrorKindArity
+	// ErrorKindRange is returned when a value is out of the range an
+	// operation accepts.
+	ErrorKindRange
+	// ErrorKindCall is returned when a host function fails.
+	ErrorKindCall
+	// ErrorKindStackOverflow is returned when calls are nested too deeply.
+	ErrorKindStackOverflow
+	// ErrorKindInternal is returned when the evaluator itself misbehaves.
+	ErrorKindInternal
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindUndefined:
+		return "undefined"
+	case ErrorKindType:
+		return "type"
+	case ErrorKindArity:
+		return "arity"
+	case ErrorKindRange:
+		return "range"
+	case ErrorKindCall:
+		return "call"
+	case ErrorKindStackOverflow:
+		return "stack overflow"
+	case ErrorKindInternal:
+		return "internal"
+	default:
+		return "unknown"
+	}
+}
+
+// Frame is a function call that was in progress when a RuntimeError occurred.
+// Span is the source range of the call expression.
+type Frame struct {
+	Name string
+	Span lexer.Span
+}
+
+// RuntimeError aborts the evaluation. Span is the source range of the
+// expression that failed, and Stack lists the calls that were in progress,
+// innermost first.
+type RuntimeError struct {
+	Kind    ErrorKind
+	Message string
+	Span    lexer.Span
+	Stack   []Frame
+	// Err is the underlying error, if any (e.g. returned by a host function).
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Span.Start, e.Message)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Errorf creates a RuntimeError of the given kind. Host functions can return
+// it to control the kind of the error, the evaluator fills in the source span
+// and call stack.
+func Errorf(kind ErrorKind, format string, args ...any) *RuntimeError {
+	return &RuntimeError{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -1,9 +1,10 @@
 package evaluator
 
 import (
+	"cmp"
 	"errors"
-	"log"
 	"math"
+	"slices"
 
 	"github.com/corani/bantamgo/ast"
 	"github.com/corani/bantamgo/lexer"
@@ -11,228 +12,928 @@ import (
 
 var ErrStackUnderflow = errors.New("stack underflow")
 
-type Function = func([]float64) float64
-
-type SymbolKind int
+// loopSignal is panicked by break and continue, and recovered by the loop
+// they're in. The parser makes sure they're always inside a loop.
+type loopSignal int
 
 const (
-	SymbolKindNumber SymbolKind = iota
-	SymbolKindFunction
-	SymbolKindUndefined
+	signalNone loopSignal = iota
+	signalBreak
+	signalContinue
 )
 
-type Symbol struct {
-	Name       string
-	Kind       SymbolKind
-	AsNumber   float64
-	AsFunction Function
-}
+// maxCallDepth limits the nesting of function calls, so runaway recursion is
+// reported as an error instead of crashing the process.
+const maxCallDepth = 1000
 
 func New() *eval {
 	res := &eval{
-		stack:  make([]Symbol, 0),
-		locals: make(map[string]Symbol),
+		stack: make([]Value, 0),
+		env:   NewEnvironment(nil),
 	}
 
-	res.defineFunction("pow", func(args []float64) float64 {
-		if len(args) != 2 {
-			return 0
-		}
+	res.env.Define("nil", Nil{})
+	res.env.Define("true", Bool(true))
+	res.env.Define("false", Bool(false))
 
-		return math.Pow(args[0], args[1])
-	})
+	defineBuiltins(res.env)
 
 	return res
 }
 
+// Define binds a name in the global scope, to pass inputs to the script.
+func (e *eval) Define(name string, value Value) {
+	e.env.Define(name, value)
+}
+
 type eval struct {
-	stack  []Symbol
-	locals map[string]Symbol
+	stack  []Value
+	env    *Environment
+	node   ast.Node
+	frames []Frame
 }
 
-func (e *eval) Answer() float64 {
-	return e.popNumber()
+// Eval evaluates the expression and returns its value. Evaluation stops at the
+// first error, which is returned as a *RuntimeError.
+func (e *eval) Eval(expr ast.Expression) (result Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(*RuntimeError)
+			if !ok {
+				panic(r)
+			}
+
+			e.stack = e.stack[:0]
+			e.frames = nil
+
+			err = failure
+		}
+	}()
+
+	return e.evaluate(expr), nil
 }
 
 func (e *eval) VisitBlock(expressions []ast.Expression) {
+	defer e.enter(NewEnvironment(e.env))()
+
+	// The value of a block is the value of its last expression.
+	var result Value = Nil{}
+
 	for _, expr := range expressions {
-		expr.Visit(e)
+		result = e.evaluate(expr)
 	}
+
+	e.push(result)
 }
 
 func (e *eval) VisitName(name string) {
-	if val, ok := e.locals[name]; ok {
-		e.push(val)
-	} else {
-		// TODO(daniel): we should probably do this in an earlier type-checking phase.
-		e.push(Symbol{Name: name, Kind: SymbolKindUndefined})
+	val, ok := e.env.Get(name)
+	if !ok {
+		e.fail(Errorf(ErrorKindUndefined, "undefined name %q", name))
 	}
+
+	e.push(val)
+}
+
+func (e *eval) VisitNumber(_ string, value float64) {
+	e.push(Float(value))
+}
+
+func (e *eval) VisitInteger(_ string, value int64) {
+	e.push(Int(value))
 }
 
-func (e *eval) VisitNumber(value float64) {
-	e.pushNumber(value)
+func (e *eval) VisitString(value string) {
+	e.push(String(value))
 }
 
 func (e *eval) VisitAssign(name string, right ast.Expression) {
-	right.Visit(e)
+	val := e.evaluate(right)
 
-	e.defineNumber(name, e.popNumber())
+	e.env.Set(name, val)
+	e.push(val)
 }
 
 func (e *eval) VisitConditional(condition, thenBranch, elseBranch ast.Expression) {
-	condition.Visit(e)
-
-	if int64(e.popNumber()) != 0 {
-		thenBranch.Visit(e)
+	if Truthy(e.evaluate(condition)) {
+		e.push(e.evaluate(thenBranch))
 	} else {
-		elseBranch.Visit(e)
+		e.push(e.evaluate(elseBranch))
 	}
 }
 
-func (e *eval) VisitCall(callee ast.Expression, arguments []ast.Expression) {
-	callee.Visit(e)
+func (e *eval) VisitCall(callee ast.Expression, arguments []ast.Expression, named []ast.NamedArgument) {
+	fn := e.evaluate(callee)
+	args := make([]Value, 0, len(arguments))
+	values := make([]namedValue, 0, len(named))
 
-	fn := e.popFunction()
-	args := make([]float64, 0, len(arguments))
+	if fn.Kind() != ValueKindFunction {
+		e.failAt(callee.Span(), Errorf(ErrorKindType, "expected a function, got %v", fn.Kind()))
+	}
 
 	for _, arg := range arguments {
-		arg.Visit(e)
-		args = append(args, e.popNumber())
+		args = append(args, e.evaluate(arg))
+	}
+
+	for _, arg := range named {
+		values = append(values, namedValue{name: arg.Name, value: e.evaluate(arg.Value)})
 	}
 
-	ans := fn(args)
+	name := "<anonymous>"
 
-	e.pushNumber(ans)
+	if callee, ok := callee.(*ast.NameExpressionNode); ok {
+		name = callee.Name
+	}
+
+	e.push(e.call(name, fn, args, values))
 }
 
-func (e *eval) VisitPrefix(operator lexer.TokenType, right ast.Expression) {
-	right.Visit(e)
+// namedValue is the value of a named argument.
+type namedValue struct {
+	name  string
+	value Value
+}
+
+// call calls a closure or host function, recording the call on the call stack
+// under the given name.
+func (e *eval) call(name string, fn Value, args []Value, named []namedValue) Value {
+	e.enterCall(name)
+	defer e.leaveCall()
+
+	switch fn := fn.(type) {
+	case *Closure:
+		return e.callClosure(fn, args, named)
+	case Function:
+		names := make([]string, len(fn.Params))
+		required := 0
+
+		for i, param := range fn.Params {
+			names[i] = param.Name
+
+			if param.Default == nil {
+				required = i + 1
+			}
+		}
+
+		args = e.arrange(names, required, args, named)
+
+		for i, arg := range args {
+			if arg == nil {
+				args[i] = fn.Params[i].Default
+			}
+		}
+
+		ans, err := fn.Fn(args)
+		if err != nil {
+			var failure *RuntimeError
+
+			if !errors.As(err, &failure) {
+				failure = &RuntimeError{Kind: ErrorKindCall, Message: err.Error(), Err: err}
+			}
+
+			e.fail(failure)
+		}
+
+		return ans
+	default:
+		e.fail(Errorf(ErrorKindType, "expected a function, got %v", fn.Kind()))
+
+		return nil
+	}
+}
+
+func (e *eval) VisitOperator(operator string, precedence int, rightAssoc bool, function ast.Expression) {
+	fn := e.evaluate(function)
+
+	if fn.Kind() != ValueKindFunction {
+		e.failAt(function.Span(), Errorf(ErrorKindType, "expected a function, got %v", fn.Kind()))
+	}
+
+	e.env.Define(operator, fn)
+	e.push(fn)
+}
+
+func (e *eval) VisitIf(condition, thenBranch, elseBranch ast.Expression) {
+	switch {
+	case Truthy(e.evaluate(condition)):
+		e.push(e.evaluate(thenBranch))
+	case elseBranch != nil:
+		e.push(e.evaluate(elseBranch))
+	default:
+		e.push(Nil{})
+	}
+}
+
+// VisitWhile runs the body as long as the condition holds. The value of the
+// loop is the value of the last completed iteration, or nil if there's none.
+func (e *eval) VisitWhile(condition, body ast.Expression) {
+	var result Value = Nil{}
+
+	for Truthy(e.evaluate(condition)) {
+		value, signal := e.iterate(body)
+		if value != nil {
+			result = value
+		}
+
+		if signal == signalBreak {
+			break
+		}
+	}
+
+	e.push(result)
+}
+
+// VisitFor runs the body for each int in the half-open range [start, end),
+// each time in a new scope with the loop variable bound to the current int.
+func (e *eval) VisitFor(name string, start, end, body ast.Expression) {
+	from := e.expectInt(start, e.evaluate(start))
+	to := e.expectInt(end, e.evaluate(end))
+
+	var result Value = Nil{}
+
+	for i := from; i < to; i++ {
+		value, signal := e.iterateWith(name, i, body)
+		if value != nil {
+			result = value
+		}
+
+		if signal == signalBreak {
+			break
+		}
+	}
+
+	e.push(result)
+}
+
+func (e *eval) VisitBreak() {
+	panic(signalBreak)
+}
+
+func (e *eval) VisitContinue() {
+	panic(signalContinue)
+}
+
+// iterateWith evaluates an iteration of a for loop, with name bound to i.
+func (e *eval) iterateWith(name string, i Int, body ast.Expression) (Value, loopSignal) {
+	defer e.enter(NewEnvironment(e.env))()
+
+	e.env.Define(name, i)
+
+	return e.iterate(body)
+}
+
+// iterate evaluates an iteration of a loop. If the iteration is cut short by
+// break or continue, the value is nil and the signal tells which one.
+func (e *eval) iterate(body ast.Expression) (value Value, signal loopSignal) {
+	depth := len(e.stack)
+
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+
+			if signal, ok = r.(loopSignal); !ok {
+				panic(r)
+			}
+
+			e.stack = e.stack[:depth]
+		}
+	}()
+
+	return e.evaluate(body), signalNone
+}
+
+func (e *eval) VisitList(elements []ast.Expression) {
+	list := make(List, 0, len(elements))
+
+	for _, element := range elements {
+		list = append(list, e.evaluate(element))
+	}
+
+	e.push(list)
+}
+
+func (e *eval) VisitIndex(target, index ast.Expression) {
+	list := e.expectList(target, e.evaluate(target))
+	i := e.expectInt(index, e.evaluate(index))
+
+	if i < 0 || int(i) >= len(list) {
+		e.failAt(index.Span(), Errorf(ErrorKindRange, "index %d out of range for list of length %d", i, len(list)))
+	}
+
+	e.push(list[i])
+}
+
+// VisitSlice takes the elements from start up to, but not including, end. A
+// missing start or end means the start or end of the list.
+func (e *eval) VisitSlice(target, start, end ast.Expression) {
+	list := e.expectList(target, e.evaluate(target))
+	from, to := Int(0), Int(len(list))
+
+	if start != nil {
+		from = e.expectInt(start, e.evaluate(start))
+	}
+
+	if end != nil {
+		to = e.expectInt(end, e.evaluate(end))
+	}
+
+	if from < 0 || from > to || int(to) > len(list) {
+		e.fail(Errorf(ErrorKindRange, "slice bounds [%d:%d] out of range for list of length %d", from, to, len(list)))
+	}
+
+	e.push(list[from:to:to])
+}
+
+func (e *eval) VisitRecord(fields []ast.Field) {
+	record := make(Record, len(fields))
+
+	for _, field := range fields {
+		record[field.Name] = e.evaluate(field.Value)
+	}
+
+	e.push(record)
+}
+
+func (e *eval) VisitMember(target ast.Expression, name string) {
+	val := e.evaluate(target)
+
+	record, ok := val.(Record)
+	if !ok {
+		e.failAt(target.Span(), Errorf(ErrorKindType, "expected a record, got %v", val.Kind()))
+	}
+
+	field, ok := record[name]
+	if !ok {
+		e.fail(Errorf(ErrorKindUndefined, "undefined field %q", name))
+	}
+
+	e.push(field)
+}
+
+func (e *eval) VisitTuple(elements []ast.Expression) {
+	e.VisitList(elements)
+}
+
+// VisitDestructure assigns the parts of the value to the names in the pattern.
+// The pattern visitor methods take the value to match from the stack.
+func (e *eval) VisitDestructure(pattern ast.Pattern, right ast.Expression) {
+	val := e.evaluate(right)
+
+	e.bind(pattern, val)
+	e.push(val)
+}
+
+func (e *eval) VisitNamePattern(name string) {
+	e.env.Set(name, e.pop())
+}
+
+func (e *eval) VisitTuplePattern(elements []ast.Pattern) {
+	e.VisitListPattern(elements)
+}
+
+func (e *eval) VisitListPattern(elements []ast.Pattern) {
+	val := e.pop()
+
+	list, ok := val.(List)
+	if !ok {
+		e.fail(Errorf(ErrorKindType, "expected a list, got %v", val.Kind()))
+	}
+
+	if len(list) != len(elements) {
+		e.fail(Errorf(ErrorKindArity, "wrong number of values to unpack: expected %d, got %d", len(elements), len(list)))
+	}
+
+	for i, element := range elements {
+		e.bind(element, list[i])
+	}
+}
+
+func (e *eval) VisitRecordPattern(fields []ast.FieldPattern) {
+	val := e.pop()
+
+	record, ok := val.(Record)
+	if !ok {
+		e.fail(Errorf(ErrorKindType, "expected a record, got %v", val.Kind()))
+	}
+
+	for _, field := range fields {
+		fieldVal, ok := record[field.Name]
+		if !ok {
+			e.fail(Errorf(ErrorKindUndefined, "undefined field %q", field.Name))
+		}
+
+		e.bind(field.Pattern, fieldVal)
+	}
+}
+
+// bind matches the value against the pattern. Errors are reported at the
+// pattern.
+func (e *eval) bind(pattern ast.Pattern, val Value) {
+	prev := e.node
+	e.node = pattern
+
+	defer func() {
+		e.node = prev
+	}()
+
+	e.push(val)
+	pattern.Visit(e)
+}
+
+func (e *eval) VisitLambda(params []ast.Parameter, body ast.Expression) {
+	e.push(&Closure{
+		Params: params,
+		Body:   body,
+		Env:    e.env,
+	})
+}
+
+// callClosure evaluates the body of the closure in a new function scope, on top
+// of the environment it captured, with its parameters bound to the arguments.
+// Defaults are evaluated in that scope, so they can refer to the parameters
+// before them.
+func (e *eval) callClosure(closure *Closure, args []Value, named []namedValue) Value {
+	names := make([]string, len(closure.Params))
+	required := 0
+
+	for i, param := range closure.Params {
+		names[i] = param.Name
+
+		if param.Default == nil {
+			required = i + 1
+		}
+	}
+
+	args = e.arrange(names, required, args, named)
 
-	val := e.popNumber()
+	defer e.enter(newFunctionEnvironment(closure.Env))()
+
+	for i, param := range closure.Params {
+		if args[i] == nil {
+			args[i] = e.evaluate(param.Default)
+		}
+
+		e.env.Define(param.Name, args[i])
+	}
+
+	return e.evaluate(closure.Body)
+}
+
+// arrange matches the arguments of a call to the parameters, first by position
+// and then by name. The first required parameters must be given, the others
+// are left nil if they're missing, to be filled in with their defaults.
+func (e *eval) arrange(params []string, required int, args []Value, named []namedValue) []Value {
+	if len(args) > len(params) || (len(args) < required && len(named) == 0) {
+		if required == len(params) {
+			e.fail(Errorf(ErrorKindArity, "wrong number of arguments: expected %d, got %d", required, len(args)))
+		}
+
+		e.fail(Errorf(ErrorKindArity, "wrong number of arguments: expected %d to %d, got %d", required, len(params), len(args)))
+	}
+
+	res := make([]Value, len(params))
+	copy(res, args)
+
+	for _, arg := range named {
+		i := slices.Index(params, arg.name)
+
+		switch {
+		case i < 0:
+			e.fail(Errorf(ErrorKindArity, "unknown argument %q", arg.name))
+		case res[i] != nil:
+			e.fail(Errorf(ErrorKindArity, "duplicate argument %q", arg.name))
+		}
+
+		res[i] = arg.value
+	}
+
+	for i, arg := range res[:required] {
+		if arg == nil {
+			e.fail(Errorf(ErrorKindArity, "missing argument %q", params[i]))
+		}
+	}
+
+	return res
+}
+
+func (e *eval) VisitPrefix(operator lexer.TokenType, right ast.Expression) {
+	val := e.evaluate(right)
 
 	switch operator {
 	case lexer.TypePlus:
-		e.pushNumber(val)
+		e.expectNumber(right, val)
+		e.push(val)
 	case lexer.TypeMinus:
-		e.pushNumber(-val)
-	case lexer.TypeTilde:
-		e.pushNumber(float64(^int64(val)))
-	case lexer.TypeBang:
-		if int64(val) == 0 {
-			e.pushNumber(1)
+		e.expectNumber(right, val)
+
+		if i, ok := val.(Int); ok {
+			if i == math.MinInt64 {
+				e.fail(Errorf(ErrorKindRange, "negation of %d overflows", i))
+			}
+
+			e.push(-i)
 		} else {
-			e.pushNumber(0)
+			e.push(-val.(Float))
 		}
+	case lexer.TypeTilde:
+		e.push(^e.expectInt(right, val))
+	case lexer.TypeBang:
+		e.push(Bool(!Truthy(val)))
+	default:
+		e.unsupported(operator)
 	}
 }
 
 func (e *eval) VisitInfix(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
-	left.Visit(e)
-	lhs := e.popNumber()
+	lhs := e.evaluate(left)
+	rhs := e.evaluate(right)
 
-	right.Visit(e)
-	rhs := e.popNumber()
+	// Operators added to a Grammar can be implemented by binding a function
+	// to their spelling, which can't clash with a name.
+	if fn, ok := e.env.Get(operator.String()); ok {
+		e.push(e.call(operator.String(), fn, []Value{lhs, rhs}, nil))
+
+		return
+	}
+
+	switch operator {
+	case lexer.TypeEqual:
+		e.push(Bool(Equal(lhs, rhs)))
+	case lexer.TypeNotEqual:
+		e.push(Bool(!Equal(lhs, rhs)))
+	case lexer.TypeLess, lexer.TypeLessEqual, lexer.TypeGreater, lexer.TypeGreaterEqual:
+		e.push(Bool(e.compare(operator, left, lhs, right, rhs)))
+	case lexer.TypePercent, lexer.TypeBackslash, lexer.TypeAmpersand, lexer.TypePipe, lexer.TypeTilde,
+		lexer.TypeShiftLeft, lexer.TypeShiftRight:
+		e.push(e.integer(operator, left, lhs, right, rhs))
+	default:
+		e.push(e.arithmetic(operator, left, lhs, right, rhs))
+	}
+}
+
+// VisitScriptInfix calls the function bound to the spelling of an operator
+// declared in the script.
+func (e *eval) VisitScriptInfix(left ast.Expression, operator string, right ast.Expression) {
+	lhs := e.evaluate(left)
+	rhs := e.evaluate(right)
+
+	fn, ok := e.env.Get(operator)
+	if !ok {
+		e.fail(Errorf(ErrorKindUndefined, "undefined operator %q", operator))
+	}
+
+	e.push(e.call(operator, fn, []Value{lhs, rhs}, nil))
+}
+
+func (e *eval) VisitLogical(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	lhs := Truthy(e.evaluate(left))
+
+	// Only evaluate the right-hand side if it determines the result.
+	switch {
+	case operator == lexer.TypeLogicalAnd && !lhs:
+		e.push(Bool(false))
+	case operator == lexer.TypeLogicalOr && lhs:
+		e.push(Bool(true))
+	default:
+		e.push(Bool(Truthy(e.evaluate(right))))
+	}
+}
+
+func (e *eval) VisitPostfix(left ast.Expression, operator lexer.TokenType) {
+	val := e.evaluate(left)
+
+	switch operator {
+	case lexer.TypeBang:
+		n := e.expectInt(left, val)
+		if n < 0 {
+			e.failAt(left.Span(), Errorf(ErrorKindRange, "factorial of negative number %d", n))
+		}
+
+		ans := Int(1)
+
+		for i := Int(1); i <= n; i++ {
+			if ans > math.MaxInt64/i {
+				e.fail(Errorf(ErrorKindRange, "factorial of %d overflows", n))
+			}
+
+			ans *= i
+		}
+
+		e.push(ans)
+	default:
+		e.unsupported(operator)
+	}
+}
+
+// arithmetic applies an arithmetic operator to two numbers. If both are ints,
+// so is the result, except for "/" which always results in a float. Results
+// that overflow an int are range errors. Adding anything to a string
+// concatenates them.
+func (e *eval) arithmetic(operator lexer.TokenType, left ast.Expression, lhs Value, right ast.Expression, rhs Value) Value {
+	if operator == lexer.TypePlus && (lhs.Kind() == ValueKindString || rhs.Kind() == ValueKindString) {
+		e.expectConcat(left, lhs)
+		e.expectConcat(right, rhs)
+
+		return String(lhs.String() + rhs.String())
+	}
+
+	e.expectNumber(left, lhs)
+	e.expectNumber(right, rhs)
+
+	if x, ok := lhs.(Int); ok {
+		if y, ok := rhs.(Int); ok {
+			switch operator {
+			case lexer.TypePlus:
+				return e.exact(operator, x, y, addInt)
+			case lexer.TypeMinus:
+				return e.exact(operator, x, y, subInt)
+			case lexer.TypeAsterisk:
+				return e.exact(operator, x, y, mulInt)
+			case lexer.TypeCaret:
+				if y >= 0 {
+					return e.exact(operator, x, y, powInt)
+				}
+			}
+		}
+	}
+
+	x, _ := toFloat(lhs)
+	y, _ := toFloat(rhs)
 
 	switch operator {
 	case lexer.TypePlus:
-		e.pushNumber(lhs + rhs)
+		return Float(x + y)
 	case lexer.TypeMinus:
-		e.pushNumber(lhs - rhs)
+		return Float(x - y)
 	case lexer.TypeAsterisk:
-		e.pushNumber(lhs * rhs)
+		return Float(x * y)
 	case lexer.TypeSlash:
-		e.pushNumber(lhs / rhs)
+		if y == 0 {
+			e.failAt(right.Span(), Errorf(ErrorKindRange, "division by zero"))
+		}
+
+		return Float(x / y)
 	case lexer.TypeCaret:
-		e.pushNumber(math.Pow(lhs, rhs))
+		return Float(math.Pow(x, y))
+	default:
+		e.unsupported(operator)
+
+		return nil
 	}
 }
 
-func (e *eval) VisitPostfix(left ast.Expression, operator lexer.TokenType) {
-	left.Visit(e)
+// expectConcat fails with a type error unless v can be added to a string.
+func (e *eval) expectConcat(expr ast.Expression, v Value) {
+	if _, ok := toFloat(v); !ok && v.Kind() != ValueKindString {
+		e.failAt(expr.Span(), Errorf(ErrorKindType, "expected a string or a number, got %v", v.Kind()))
+	}
+}
+
+// exact applies an operation on ints, and fails with a range error if the
+// result overflows.
+func (e *eval) exact(operator lexer.TokenType, x, y Int, op func(x, y Int) (Int, bool)) Int {
+	result, ok := op(x, y)
+	if !ok {
+		e.fail(Errorf(ErrorKindRange, "%d %v %d overflows", x, operator, y))
+	}
 
-	val := uint64(e.popNumber())
+	return result
+}
+
+// integer applies an operator that is only defined for ints. Division and
+// remainder truncate towards zero, so that x == (x \ y) * y + x % y.
+func (e *eval) integer(operator lexer.TokenType, left ast.Expression, lhs Value, right ast.Expression, rhs Value) Int {
+	x := e.expectInt(left, lhs)
+	y := e.expectInt(right, rhs)
 
 	switch operator {
-	case lexer.TypeBang:
-		ans := uint64(1)
+	case lexer.TypePercent, lexer.TypeBackslash:
+		if y == 0 {
+			e.failAt(right.Span(), Errorf(ErrorKindRange, "division by zero"))
+		}
 
-		for i := uint64(1); i <= val; i++ {
-			ans *= i
+		if operator == lexer.TypePercent {
+			return x % y
+		}
+
+		if x == math.MinInt64 && y == -1 {
+			e.fail(Errorf(ErrorKindRange, "%d %v %d overflows", x, operator, y))
 		}
 
-		e.pushNumber(float64(ans))
+		return x / y
+	case lexer.TypeAmpersand:
+		return x & y
+	case lexer.TypePipe:
+		return x | y
+	case lexer.TypeTilde:
+		return x ^ y
+	case lexer.TypeShiftLeft, lexer.TypeShiftRight:
+		if y < 0 {
+			e.failAt(right.Span(), Errorf(ErrorKindRange, "negative shift count %d", y))
+		}
+
+		if operator == lexer.TypeShiftLeft {
+			if x != 0 && (y >= 64 || (x<<y)>>y != x) {
+				e.fail(Errorf(ErrorKindRange, "%d %v %d overflows", x, operator, y))
+			}
+
+			return x << y
+		}
+
+		// Shifting right is arithmetic, it keeps the sign.
+		return x >> y
+	default:
+		e.unsupported(operator)
+
+		return 0
 	}
 }
 
-func (e *eval) define(value Symbol) {
-	e.locals[value.Name] = value
+// compare orders two numbers or two strings with the given comparison
+// operator. Like in Go, NaN is unordered, so every comparison with it is false.
+func (e *eval) compare(operator lexer.TokenType, left ast.Expression, lhs Value, right ast.Expression, rhs Value) bool {
+	var result int
+
+	if x, ok := lhs.(String); ok {
+		y, ok := rhs.(String)
+		if !ok {
+			e.failAt(right.Span(), Errorf(ErrorKindType, "expected a string, got %v", rhs.Kind()))
+		}
+
+		result = cmp.Compare(x, y)
+	} else {
+		e.expectNumber(left, lhs)
+		e.expectNumber(right, rhs)
+
+		if result, ok = compareNumbers(lhs, rhs); !ok {
+			return false
+		}
+	}
+
+	switch operator {
+	case lexer.TypeLess:
+		return result < 0
+	case lexer.TypeLessEqual:
+		return result <= 0
+	case lexer.TypeGreater:
+		return result > 0
+	default:
+		return result >= 0
+	}
 }
 
-func (e *eval) defineNumber(name string, value float64) {
-	e.define(Symbol{Name: name, Kind: SymbolKindNumber, AsNumber: value})
+// addInt, subInt and mulInt return the result of the operation, and false if
+// it overflows.
+func addInt(x, y Int) (Int, bool) {
+	result := x + y
+
+	return result, (result > x) == (y > 0)
 }
 
-func (e *eval) defineFunction(name string, fn Function) {
-	e.define(Symbol{Name: name, Kind: SymbolKindFunction, AsFunction: fn})
+func subInt(x, y Int) (Int, bool) {
+	result := x - y
+
+	return result, (result < x) == (y > 0)
 }
 
-func (e *eval) push(value Symbol) {
-	e.stack = append(e.stack, value)
+func mulInt(x, y Int) (Int, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+
+	if (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return 0, false
+	}
+
+	result := x * y
+
+	return result, result/y == x
 }
 
-func (e *eval) pushNumber(value float64) {
-	e.push(Symbol{Kind: SymbolKindNumber, AsNumber: value})
+// powInt raises x to the non-negative power y by repeated squaring, and returns
+// false if the result overflows. x is only squared while it's still needed, so
+// squaring overflows only if the result does too.
+func powInt(x, y Int) (Int, bool) {
+	result := Int(1)
+
+	for ok := true; y > 0; {
+		if y&1 == 1 {
+			if result, ok = mulInt(result, x); !ok {
+				return 0, false
+			}
+		}
+
+		if y >>= 1; y > 0 {
+			if x, ok = mulInt(x, x); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return result, true
 }
 
-func (e *eval) pop() (*Symbol, error) {
-	if len(e.stack) == 0 {
-		return nil, ErrStackUnderflow
+// evaluate visits the expression and returns its value. While visiting, expr
+// is the current node that errors are reported against.
+func (e *eval) evaluate(expr ast.Expression) Value {
+	prev := e.node
+	e.node = expr
+
+	defer func() {
+		e.node = prev
+	}()
+
+	expr.Visit(e)
+
+	return e.pop()
+}
+
+// expectNumber fails with a type error if the value of expr isn't an int or a
+// float.
+func (e *eval) expectNumber(expr ast.Expression, val Value) {
+	if _, ok := toFloat(val); !ok {
+		e.failAt(expr.Span(), Errorf(ErrorKindType, "expected a number, got %v", val.Kind()))
 	}
+}
 
-	value := e.stack[len(e.stack)-1]
-	e.stack = e.stack[:len(e.stack)-1]
+// expectList fails with a type error if the value of expr isn't a list.
+func (e *eval) expectList(expr ast.Expression, val Value) List {
+	list, ok := val.(List)
+	if !ok {
+		e.failAt(expr.Span(), Errorf(ErrorKindType, "expected a list, got %v", val.Kind()))
+	}
 
-	return &value, nil
+	return list
 }
 
-func (e *eval) popNumber() float64 {
-	// TODO(daniel): not sure if this error recovery is a good idea.
-	defaultVal := 0.0
+// expectInt fails with a type error if the value of expr isn't an int.
+func (e *eval) expectInt(expr ast.Expression, val Value) Int {
+	i, ok := val.(Int)
+	if !ok {
+		e.failAt(expr.Span(), Errorf(ErrorKindType, "expected an int, got %v", val.Kind()))
+	}
 
-	val, err := e.pop()
-	if err != nil {
-		log.Println(err)
-	} else {
-		switch val.Kind {
-		case SymbolKindNumber:
-			return val.AsNumber
-		case SymbolKindUndefined:
-			log.Printf("Undefined symbol %q", val.Name)
-		default:
-			log.Printf("%q is not a number", val.Name)
-		}
+	return i
+}
+
+// enter makes env the current scope, and returns a function that restores the
+// previous one.
+func (e *eval) enter(env *Environment) func() {
+	prev := e.env
+	e.env = env
+
+	return func() {
+		e.env = prev
+	}
+}
+
+// enterCall records a call on the call stack.
+func (e *eval) enterCall(name string) {
+	if len(e.frames) >= maxCallDepth {
+		e.fail(Errorf(ErrorKindStackOverflow, "maximum call depth of %d exceeded", maxCallDepth))
 	}
 
-	return defaultVal
+	e.frames = append(e.frames, Frame{Name: name, Span: e.node.Span()})
 }
 
-func (e *eval) popFunction() Function {
-	// TODO(daniel): not sure if this error recovery is a good idea.
-	defaultFunc := func([]float64) float64 { return 0 }
+func (e *eval) leaveCall() {
+	e.frames = e.frames[:len(e.frames)-1]
+}
 
-	val, err := e.pop()
-	if err != nil {
-		log.Println(err)
-	} else {
-		switch val.Kind {
-		case SymbolKindFunction:
-			return val.AsFunction
-		case SymbolKindUndefined:
-			log.Printf("Undefined symbol %q", val.Name)
-		default:
-			log.Printf("%q is not a function", val.Name)
-		}
+// fail aborts the evaluation with an error for the current node.
+func (e *eval) fail(err *RuntimeError) {
+	e.failAt(e.node.Span(), err)
+}
+
+// failAt aborts the evaluation with an error for the given span. The error is
+// recovered in Eval.
+func (e *eval) failAt(span lexer.Span, err *RuntimeError) {
+	failure := *err
+	failure.Span = span
+	failure.Stack = make([]Frame, 0, len(e.frames))
+
+	for i := len(e.frames) - 1; i >= 0; i-- {
+		failure.Stack = append(failure.Stack, e.frames[i])
+	}
+
+	panic(&failure)
+}
+
+func (e *eval) unsupported(operator lexer.TokenType) {
+	e.fail(Errorf(ErrorKindInternal, "unsupported operator %q", operator.String()))
+}
+
+func (e *eval) push(value Value) {
+	e.stack = append(e.stack, value)
+}
+
+func (e *eval) pop() Value {
+	if len(e.stack) == 0 {
+		e.fail(&RuntimeError{Kind: ErrorKindInternal, Message: ErrStackUnderflow.Error(), Err: ErrStackUnderflow})
 	}
 
-	return defaultFunc
+	value := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return value
 }
@@ -0,0 +1,357 @@
+package evaluator
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/corani/bantamgo/ast"
+	"github.com/corani/bantamgo/lexer"
+)
+
+type ValueKind int
+
+const (
+	ValueKindNil ValueKind = iota
+	ValueKindBool
+	ValueKindInt
+	ValueKindFloat
+	ValueKindString
+	ValueKindFunction
+	ValueKindList
+	ValueKindRecord
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case ValueKindNil:
+		return "nil"
+	case ValueKindBool:
+		return "bool"
+	case ValueKindInt:
+		return "int"
+	case ValueKindFloat:
+		return "float"
+	case ValueKindString:
+		return "string"
+	case ValueKindFunction:
+		return "function"
+	case ValueKindList:
+		return "list"
+	case ValueKindRecord:
+		return "record"
+	default:
+		return "unknown"
+	}
+}
+
+// Value is the result of evaluating an expression.
+//
+// The evaluator applies these coercion rules:
+//   - arithmetic on two ints results in an int, except for "/" which always
+//     results in a float. If either side is a float, so is the result. Other
+//     kinds are a type error;
+//   - adding a string and a string or a number concatenates their string
+//     forms. Adding a string and any other kind is a type error;
+//   - numbers are compared by value, regardless of int or float, strings are
+//     ordered byte-wise. Ordering other kinds is a type error, while equality
+//     between different kinds is simply false;
+//   - conditions accept any value: nil, false, zero, the empty string, list and
+//     record are false, everything else is true. Logical operators result in a
+//     bool;
+//   - lists are equal if their elements are, and are indexed by ints;
+//   - records are equal if they have the same fields with equal values;
+//   - bitwise operators and factorial only accept ints.
+type Value interface {
+	Kind() ValueKind
+	String() string
+}
+
+type Nil struct{}
+
+func (Nil) Kind() ValueKind {
+	return ValueKindNil
+}
+
+func (Nil) String() string {
+	return "nil"
+}
+
+type Bool bool
+
+func (Bool) Kind() ValueKind {
+	return ValueKindBool
+}
+
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+type Int int64
+
+func (Int) Kind() ValueKind {
+	return ValueKindInt
+}
+
+func (i Int) String() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+type Float float64
+
+func (Float) Kind() ValueKind {
+	return ValueKindFloat
+}
+
+// String formats the float so that it can't be mistaken for an int.
+func (f Float) String() string {
+	text := strconv.FormatFloat(float64(f), 'g', -1, 64)
+
+	if !strings.ContainsAny(text, ".eIN") {
+		text += ".0"
+	}
+
+	return text
+}
+
+type String string
+
+func (String) Kind() ValueKind {
+	return ValueKindString
+}
+
+func (s String) String() string {
+	return string(s)
+}
+
+// List is an immutable sequence of values.
+type List []Value
+
+func (List) Kind() ValueKind {
+	return ValueKindList
+}
+
+// String formats the list like a list literal, with strings quoted.
+func (l List) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("[")
+
+	for i, v := range l {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(inspect(v))
+	}
+
+	sb.WriteString("]")
+
+	return sb.String()
+}
+
+// Record is an immutable set of named values.
+type Record map[string]Value
+
+func (Record) Kind() ValueKind {
+	return ValueKindRecord
+}
+
+// String formats the record like a record literal, with the fields sorted by
+// name and strings quoted.
+func (r Record) String() string {
+	if len(r) == 0 {
+		return "{:}"
+	}
+
+	var sb strings.Builder
+
+	names := make([]string, 0, len(r))
+
+	for name := range r {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	sb.WriteString("{")
+
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(inspect(r[name]))
+	}
+
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+// inspect formats a value inside a list or record, quoting strings.
+func inspect(v Value) string {
+	if s, ok := v.(String); ok {
+		return lexer.Quote(string(s))
+	}
+
+	return v.String()
+}
+
+// Function is a host function, implemented in Go. Calls are checked against
+// Params, and arguments that were left out are filled in with their defaults,
+// so Fn always receives one argument per parameter.
+type Function struct {
+	Params []Parameter
+	Fn     func(args []Value) (Value, error)
+}
+
+// Parameter is a parameter of a host function. Default is nil if the argument
+// is required.
+type Parameter struct {
+	Name    string
+	Default Value
+}
+
+func (Function) Kind() ValueKind {
+	return ValueKindFunction
+}
+
+func (Function) String() string {
+	return "<function>"
+}
+
+// Closure is a function defined in the script, together with the environment
+// it was defined in.
+type Closure struct {
+	Params []ast.Parameter
+	Body   ast.Expression
+	Env    *Environment
+}
+
+func (*Closure) Kind() ValueKind {
+	return ValueKindFunction
+}
+
+func (*Closure) String() string {
+	return "<function>"
+}
+
+// Truthy reports whether a value counts as true in a condition.
+func Truthy(v Value) bool {
+	switch v := v.(type) {
+	case Nil:
+		return false
+	case Bool:
+		return bool(v)
+	case Int:
+		return v != 0
+	case Float:
+		return v != 0
+	case String:
+		return v != ""
+	case List:
+		return len(v) > 0
+	case Record:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// Equal reports whether two values are equal. Numbers are compared by value,
+// lists and records element by element, closures by identity, and host functions are never
+// equal.
+func Equal(a, b Value) bool {
+	if _, ok := toFloat(a); ok {
+		if _, ok := toFloat(b); ok {
+			result, ok := compareNumbers(a, b)
+
+			return ok && result == 0
+		}
+	}
+
+	switch a := a.(type) {
+	case Nil:
+		return b.Kind() == ValueKindNil
+	case Bool, String, *Closure:
+		return a == b
+	case List:
+		b, ok := b.(List)
+
+		return ok && slices.EqualFunc(a, b, Equal)
+	case Record:
+		b, ok := b.(Record)
+
+		return ok && maps.EqualFunc(a, b, Equal)
+	default:
+		return false
+	}
+}
+
+// compareNumbers orders two numbers, returning -1, 0 or 1. An int is compared
+// with a float exactly, without rounding it to a float first. The result is
+// false if either number is NaN, which is unordered.
+func compareNumbers(a, b Value) (int, bool) {
+	switch x := a.(type) {
+	case Int:
+		switch y := b.(type) {
+		case Int:
+			return cmp.Compare(x, y), true
+		case Float:
+			return compareIntFloat(x, float64(y))
+		}
+	case Float:
+		switch y := b.(type) {
+		case Int:
+			result, ok := compareIntFloat(y, float64(x))
+
+			return -result, ok
+		case Float:
+			if math.IsNaN(float64(x)) || math.IsNaN(float64(y)) {
+				return 0, false
+			}
+
+			return cmp.Compare(x, y), true
+		}
+	}
+
+	return 0, false
+}
+
+// compareIntFloat orders an int and a float by comparing the int with the
+// integer part of the float first, and then the fraction with zero.
+func compareIntFloat(x Int, y float64) (int, bool) {
+	switch {
+	case math.IsNaN(y):
+		return 0, false
+	case y >= 1<<63:
+		return -1, true
+	case y < -1<<63:
+		return 1, true
+	}
+
+	whole := math.Trunc(y)
+	if result := cmp.Compare(x, Int(whole)); result != 0 {
+		return result, true
+	}
+
+	return cmp.Compare(0, y-whole), true
+}
+
+// toFloat converts ints and floats to a float64.
+func toFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v), true
+	case Float:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
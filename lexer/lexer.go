@@ -1,86 +1,429 @@
 package lexer
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// chunkSize is the number of bytes read from the input at a time.
+const chunkSize = 4096
+
+// Lexer turns the input into tokens. The input is read incrementally: only the
+// current token and the few characters of lookahead needed to scan it are
+// buffered.
 type Lexer struct {
-	text        string
-	index       int
-	punctuators map[rune]TokenType
+	reader            io.Reader
+	err               error
+	buf               []byte
+	base              int
+	index             int
+	line              int
+	column            int
+	punctuators       map[string]TokenType
+	longestPunctuator int
 }
 
 func New(text string) *Lexer {
+	return NewReader(strings.NewReader(text))
+}
+
+// NewReader creates a lexer that reads its input from r.
+func NewReader(r io.Reader) *Lexer {
 	result := &Lexer{
-		text:        text,
+		reader:      r,
 		index:       0,
-		punctuators: make(map[rune]TokenType),
+		line:        1,
+		column:      1,
+		punctuators: make(map[string]TokenType),
 	}
 
 	for _, tokenType := range TokenTypes() {
-		if tokenType >= 0 {
-			result.punctuators[rune(tokenType)] = tokenType
+		if text, ok := tokenType.Punctuator(); ok {
+			result.DefinePunctuator(text, tokenType)
 		}
 	}
 
+	for text, tokenType := range punctuatorAliases {
+		result.DefinePunctuator(text, tokenType)
+	}
+
 	return result
 }
 
+// Split returns the punctuators text is lexed as, matching the longest one
+// first, or false if part of text isn't a punctuator.
+func (l *Lexer) Split(text string) ([]Token, bool) {
+	var result []Token
+
+	for text != "" {
+		size := min(l.longestPunctuator, len(text))
+
+		for ; size > 0; size-- {
+			if tokenType, ok := l.punctuators[text[:size]]; ok {
+				result = append(result, Token{Type: tokenType, Text: text[:size]})
+
+				break
+			}
+		}
+
+		if size == 0 {
+			return nil, false
+		}
+
+		text = text[size:]
+	}
+
+	return result, true
+}
+
+// DefinePunctuator makes the lexer recognize text as a token of the given
+// type. Punctuators are matched longest first, so defining "<+>" doesn't stop
+// "<" from being recognized on its own.
+func (l *Lexer) DefinePunctuator(text string, tokenType TokenType) {
+	l.punctuators[text] = tokenType
+	l.longestPunctuator = max(l.longestPunctuator, len(text))
+}
+
 func (l *Lexer) HasNext() bool {
-	return l.index < len(l.text)
+	return l.fill(1)
 }
 
+// Next returns the next token. Whitespace and comments are attached to the
+// tokens as trivia: everything up to and including the first newline after a
+// token is trailing trivia, everything else is leading trivia of the token
+// that follows it.
 func (l *Lexer) Next() Token {
-	for l.index < len(l.text) {
-		c := rune(l.text[l.index])
+	l.discard()
+
+	leading, err := l.trivia(false)
+	if err != nil {
+		// The offending comment becomes the illegal token.
+		comment := leading[len(leading)-1]
+
+		result := l.illegal(comment.Span.Start, err)
+		result.Leading = leading[:len(leading)-1]
+
+		return result
+	}
 
-		if tokenType, ok := l.punctuators[c]; ok {
-			l.index++
+	result := l.scan()
+	result.Leading = leading
 
-			return NewToken(tokenType, string(c))
+	if result.Type != TypeEOF {
+		// Errors are ignored here, the next call to Next reports them.
+		result.Trailing, _ = l.trivia(true)
+	}
+
+	return result
+}
+
+// scan returns the next token, starting at a non-trivia character.
+func (l *Lexer) scan() Token {
+	start := l.position()
+
+	if !l.fill(1) {
+		// Report a read error once, before the end of the input.
+		if l.err != io.EOF {
+			err := l.err
+			l.err = io.EOF
+
+			return l.illegal(start, err)
 		}
 
-		// Skip whitespace
-		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
-			l.index++
+		return l.token(TypeEOF, start)
+	}
+
+	c := l.peek()
+
+	if tokenType, size := l.matchPunctuator(); size > 0 {
+		l.skip(size)
+
+		return l.token(tokenType, start)
+	}
+
+	// Parse string
+	if c == '"' {
+		l.advance()
+
+		for {
+			if !l.fill(1) || l.peek() == '\n' {
+				return l.illegal(start, errors.New("unterminated string"))
+			}
+
+			c = l.advance()
 
-			continue
+			if c == '"' {
+				break
+			}
+
+			// Skip the escaped character, so an escaped quote doesn't end
+			// the string. Unquote checks the escape itself.
+			if c == '\\' && l.fill(1) && l.peek() != '\n' {
+				l.advance()
+			}
+		}
+
+		if _, err := Unquote(l.since(start)); err != nil {
+			return l.illegal(start, err)
 		}
 
-		// Parse name
-		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' {
-			start := l.index
-			l.index++
+		return l.token(TypeString, start)
+	}
+
+	// Parse name
+	if unicode.IsLetter(c) || c == '_' {
+		l.advance()
 
-			for l.index < len(l.text) {
-				c = rune(l.text[l.index])
+		for l.fill(1) {
+			c = l.peek()
 
-				if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' {
-					l.index++
-				} else {
-					break
-				}
+			if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' {
+				l.advance()
+			} else {
+				break
 			}
+		}
 
-			return NewToken(TypeName, l.text[start:l.index])
+		if tokenType, ok := keywords[l.since(start)]; ok {
+			return l.token(tokenType, start)
 		}
 
-		// Parse number
-		if c >= '0' && c <= '9' {
-			start := l.index
-			l.index++
+		return l.token(TypeName, start)
+	}
 
-			for l.index < len(l.text) {
-				c = rune(l.text[l.index])
+	// Parse number. Take everything that could belong to it, including
+	// letters, so that malformed numbers are reported as a whole.
+	if c >= '0' && c <= '9' {
+		l.advance()
 
-				if (c < '0' || c > '9') && c != '.' {
-					break
-				}
+		for l.fill(1) {
+			c = l.peek()
+			text := l.since(start)
 
-				l.index++
+			if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' {
+				l.advance()
+			} else if c == '.' && l.fill(2) && l.buf[l.index+1] >= '0' && l.buf[l.index+1] <= '9' {
+				l.advance()
+			} else if (c == '+' || c == '-') && !HasBasePrefix(text) && strings.ContainsRune("eE", rune(text[len(text)-1])) {
+				l.advance()
+			} else {
+				break
 			}
+		}
 
-			return NewToken(TypeNumber, l.text[start:l.index])
+		if err := checkNumber(l.since(start)); err != nil {
+			return l.illegal(start, err)
 		}
+
+		return l.token(TypeNumber, start)
 	}
 
-	l.index++
+	// Anything else is an error, skip over it so we don't get stuck.
+	l.advance()
+
+	return l.illegal(start, fmt.Errorf("illegal character %q", c))
+}
+
+// trivia scans whitespace and comments. If trailing is set, it stops after the
+// first newline, and leaves an unterminated block comment for the next token.
+// Otherwise an unterminated block comment is consumed and reported as an error.
+func (l *Lexer) trivia(trailing bool) ([]Trivia, error) {
+	var result []Trivia
+
+	for l.fill(1) {
+		start := l.position()
+
+		switch {
+		case l.atNewline():
+			if l.peek() == '\r' {
+				l.advance()
+			}
+
+			l.advance()
+
+			result = append(result, l.piece(TriviaNewline, start))
+
+			if trailing {
+				return result, nil
+			}
+		case unicode.IsSpace(l.peek()):
+			for l.fill(1) && unicode.IsSpace(l.peek()) && !l.atNewline() {
+				l.advance()
+			}
+
+			result = append(result, l.piece(TriviaWhitespace, start))
+		case l.hasPrefix("//"):
+			for l.fill(1) && !l.atNewline() {
+				l.advance()
+			}
 
-	return NewToken(TypeEOF)
+			result = append(result, l.piece(TriviaLineComment, start))
+		case l.hasPrefix("/*"):
+			end := l.find("*/", 2)
+			if end < 0 && trailing {
+				return result, nil
+			}
+
+			if end < 0 {
+				l.skip(len(l.buf) - l.index)
+			} else {
+				l.skip(end + 2)
+			}
+
+			result = append(result, l.piece(TriviaBlockComment, start))
+
+			if end < 0 {
+				return result, errors.New("unterminated block comment")
+			}
+		default:
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// atNewline reports whether the current position is at a "\n" or "\r\n".
+func (l *Lexer) atNewline() bool {
+	return l.hasPrefix("\n") || l.hasPrefix("\r\n")
+}
+
+// matchPunctuator finds the longest punctuator at the current position and
+// returns its type and length in bytes, or a length of zero if there is none.
+func (l *Lexer) matchPunctuator() (TokenType, int) {
+	l.fill(l.longestPunctuator)
+
+	for size := min(l.longestPunctuator, len(l.buf)-l.index); size > 0; size-- {
+		if tokenType, ok := l.punctuators[string(l.buf[l.index:l.index+size])]; ok {
+			return tokenType, size
+		}
+	}
+
+	return TypeIllegal, 0
+}
+
+// fill makes sure at least n bytes are buffered after the current position,
+// reading more input if needed. It returns false if the input ends before that.
+func (l *Lexer) fill(n int) bool {
+	for len(l.buf)-l.index < n && l.err == nil {
+		l.buf = append(l.buf, make([]byte, chunkSize)...)
+
+		read, err := l.reader.Read(l.buf[len(l.buf)-chunkSize:])
+		l.buf = l.buf[:len(l.buf)-chunkSize+read]
+
+		if err != nil {
+			l.err = err
+		}
+	}
+
+	return len(l.buf)-l.index >= n
+}
+
+// discard drops the input before the current position from the buffer.
+func (l *Lexer) discard() {
+	l.base += l.index
+	l.buf = l.buf[:copy(l.buf, l.buf[l.index:])]
+	l.index = 0
+}
+
+// hasPrefix reports whether the input at the current position starts with s.
+func (l *Lexer) hasPrefix(s string) bool {
+	return l.fill(len(s)) && string(l.buf[l.index:l.index+len(s)]) == s
+}
+
+// find returns the position of s in the input, relative to the current
+// position and starting from the given offset, or -1 if the input ends first.
+func (l *Lexer) find(s string, from int) int {
+	for {
+		if i := bytes.Index(l.buf[l.index+from:], []byte(s)); i >= 0 {
+			return from + i
+		}
+
+		// Keep looking in the next chunk, without missing a match that
+		// straddles the chunks.
+		from = max(from, len(l.buf)-l.index-len(s)+1)
+
+		if !l.fill(len(l.buf) - l.index + 1) {
+			return -1
+		}
+	}
+}
+
+func (l *Lexer) position() Position {
+	return Position{Offset: l.base + l.index, Line: l.line, Column: l.column}
+}
+
+// since returns the input from start to the current position.
+func (l *Lexer) since(start Position) string {
+	return string(l.buf[start.Offset-l.base : l.index])
+}
+
+// peek returns the character at the current position. Invalid UTF-8 is
+// returned as utf8.RuneError.
+func (l *Lexer) peek() rune {
+	l.fill(utf8.UTFMax)
+
+	r, _ := utf8.DecodeRune(l.buf[l.index:])
+
+	return r
+}
+
+// advance moves past the current character and returns it, keeping track of
+// the line and column.
+func (l *Lexer) advance() rune {
+	l.fill(utf8.UTFMax)
+
+	r, size := utf8.DecodeRune(l.buf[l.index:])
+
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
+	l.index += size
+
+	return r
+}
+
+// skip advances past the next size bytes.
+func (l *Lexer) skip(size int) {
+	for end := l.index + size; l.index < end; {
+		l.advance()
+	}
+}
+
+// token returns a token of the given type, spanning from start to the current
+// position.
+func (l *Lexer) token(tokenType TokenType, start Position) Token {
+	return Token{
+		Type: tokenType,
+		Text: l.since(start),
+		Span: Span{Start: start, End: l.position()},
+	}
+}
+
+// piece returns trivia of the given kind, spanning from start to the current
+// position.
+func (l *Lexer) piece(kind TriviaKind, start Position) Trivia {
+	return Trivia{
+		Kind: kind,
+		Text: l.since(start),
+		Span: Span{Start: start, End: l.position()},
+	}
+}
+
+// illegal returns a TypeIllegal token spanning from start to the current
+// position.
+func (l *Lexer) illegal(start Position, err error) Token {
+	result := l.token(TypeIllegal, start)
+	result.Err = err
+
+	return result
 }
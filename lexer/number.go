@@ -0,0 +1,98 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HasBasePrefix reports whether a number literal starts with one of the "0x",
+// "0b" or "0o" prefixes.
+func HasBasePrefix(text string) bool {
+	return len(text) > 1 && text[0] == '0' && strings.ContainsRune("xXbBoO", rune(text[1]))
+}
+
+// IsFloat reports whether a number literal has a fractional part or an
+// exponent.
+func IsFloat(text string) bool {
+	return !HasBasePrefix(text) && strings.ContainsAny(text, ".eE")
+}
+
+// checkNumber validates the syntax of a number literal: decimal numbers with an
+// optional fraction and exponent, or integers with a "0x", "0b" or "0o" prefix.
+// Underscores may be used to separate digits.
+func checkNumber(text string) error {
+	if HasBasePrefix(text) {
+		digits := map[byte]string{
+			'x': "0123456789abcdefABCDEF",
+			'b': "01",
+			'o': "01234567",
+		}[text[1]|0x20]
+
+		if end := scanDigits(text, 2, digits); end == 2 || end != len(text) {
+			return fmt.Errorf("malformed number %q", text)
+		}
+
+		return nil
+	}
+
+	const decimal = "0123456789"
+
+	end := scanDigits(text, 0, decimal)
+
+	if end > 0 && end < len(text) && text[end] == '.' {
+		end = mustScanDigits(text, end+1, decimal)
+	}
+
+	if end > 0 && end < len(text) && (text[end] == 'e' || text[end] == 'E') {
+		end++
+
+		if end < len(text) && (text[end] == '+' || text[end] == '-') {
+			end++
+		}
+
+		end = mustScanDigits(text, end, decimal)
+	}
+
+	if end <= 0 || end != len(text) {
+		return fmt.Errorf("malformed number %q", text)
+	}
+
+	return nil
+}
+
+// scanDigits returns the end of the run of digits starting at start, where
+// single underscores may separate digits. It returns start if there are no
+// digits, and -1 if the underscores are misplaced.
+func scanDigits(text string, start int, digits string) int {
+	end := start
+
+	for end < len(text) {
+		if text[end] == '_' && end > start && end+1 < len(text) && strings.IndexByte(digits, text[end+1]) >= 0 {
+			end += 2
+		} else if strings.IndexByte(digits, text[end]) >= 0 {
+			end++
+		} else {
+			break
+		}
+	}
+
+	if end < len(text) && text[end] == '_' {
+		return -1
+	}
+
+	return end
+}
+
+// mustScanDigits is like scanDigits, but returns -1 if there are no digits.
+func mustScanDigits(text string, start int, digits string) int {
+	if start < 0 {
+		return -1
+	}
+
+	end := scanDigits(text, start, digits)
+	if end == start {
+		return -1
+	}
+
+	return end
+}
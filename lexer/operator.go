@@ -0,0 +1,123 @@
+package lexer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// typeOperator is the first token type handed out to operators registered with
+// Operator, later ones count down from here.
+const typeOperator TokenType = -1000
+
+// operators holds the operator spellings registered at runtime. Token types
+// are shared by all lexers, so the same spelling always gets the same type.
+var operators = struct {
+	sync.RWMutex
+	types map[string]TokenType
+	texts map[TokenType]string
+	next  TokenType
+}{
+	types: make(map[string]TokenType),
+	texts: make(map[TokenType]string),
+	next:  typeOperator,
+}
+
+// Operator returns the token type of the operator spelled text, allocating a
+// new token type the first time an unknown spelling is seen. Operators are made
+// of symbols only, so they can't be confused with names, numbers or strings.
+// Spellings that already have a token type, like "+" or "..", are reserved:
+// their parselets are registered with the Type constants instead. The lexer
+// only recognizes the operator once it's defined with Lexer.DefinePunctuator.
+//
+// The token types are shared by the whole process and never freed, so this is
+// meant for the operators of a Grammar. Operators declared in scripts are lexed
+// as TypeOperator and known by their spelling instead.
+func Operator(text string) (TokenType, error) {
+	if !IsOperator(text) {
+		return TypeIllegal, fmt.Errorf("invalid operator %q", text)
+	}
+
+	if IsReserved(text) {
+		return TypeIllegal, fmt.Errorf("operator %q is reserved", text)
+	}
+
+	if r, size := utf8.DecodeRuneInString(text); size == len(text) {
+		return TokenType(r), nil
+	}
+
+	operators.Lock()
+	defer operators.Unlock()
+
+	if tokenType, ok := operators.types[text]; ok {
+		return tokenType, nil
+	}
+
+	tokenType := operators.next
+	operators.next--
+
+	operators.types[text] = tokenType
+	operators.texts[tokenType] = text
+
+	return tokenType, nil
+}
+
+// IsOperator reports whether text is a valid operator spelling. Comment
+// markers can't appear in an operator.
+func IsOperator(text string) bool {
+	if text == "" || strings.Contains(text, "//") || strings.Contains(text, "/*") {
+		return false
+	}
+
+	for _, r := range text {
+		if !isOperatorSymbol(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsReserved reports whether text is the spelling of one of the built-in token
+// types or their aliases.
+func IsReserved(text string) bool {
+	if _, ok := punctuatorAliases[text]; ok {
+		return true
+	}
+
+	for _, spelling := range multiCharPunctuators {
+		if spelling == text {
+			return true
+		}
+	}
+
+	if r, size := utf8.DecodeRuneInString(text); size == len(text) {
+		return slices.Contains(TokenTypes(), TokenType(r))
+	}
+
+	return false
+}
+
+// isOperatorSymbol reports whether r may appear in an operator. Quotes and
+// brackets are excluded, they have a fixed meaning.
+func isOperatorSymbol(r rune) bool {
+	switch r {
+	case '"', '(', ')', '[', ']', '{', '}', ',', ';', '_':
+		return false
+	}
+
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// operatorText returns the spelling of a token type allocated by Operator.
+func operatorText(tokenType TokenType) (string, bool) {
+	operators.RLock()
+	defer operators.RUnlock()
+
+	text, ok := operators.texts[tokenType]
+
+	return text, ok
+}
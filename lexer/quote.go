@@ -0,0 +1,106 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Unquote decodes a double-quoted string literal, including the quotes. The
+// supported escapes are \n, \r, \t, \0, \\, \" and \u{...} with up to six hex
+// digits.
+func Unquote(text string) (string, error) {
+	if len(text) < 2 || text[0] != '"' || text[len(text)-1] != '"' {
+		return "", errors.New("unterminated string")
+	}
+
+	text = text[1 : len(text)-1]
+
+	var sb strings.Builder
+
+	for len(text) > 0 {
+		c := text[0]
+		text = text[1:]
+
+		if c != '\\' {
+			sb.WriteByte(c)
+
+			continue
+		}
+
+		if len(text) == 0 {
+			return "", errors.New("unterminated escape sequence")
+		}
+
+		c = text[0]
+		text = text[1:]
+
+		switch c {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '0':
+			sb.WriteByte(0)
+		case '\\', '"':
+			sb.WriteByte(c)
+		case 'u':
+			end := strings.IndexByte(text, '}')
+			if len(text) == 0 || text[0] != '{' || end < 2 || end > 7 {
+				return "", errors.New(`invalid unicode escape, expected \u{...} with 1 to 6 hex digits`)
+			}
+
+			code, err := strconv.ParseUint(text[1:end], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid unicode code point %q", text[1:end])
+			}
+
+			sb.WriteRune(rune(code))
+
+			text = text[end+1:]
+		default:
+			return "", fmt.Errorf("invalid escape sequence %q", `\`+string(c))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// Quote returns a double-quoted string literal for the value, using the escapes
+// understood by Unquote.
+func Quote(value string) string {
+	var sb strings.Builder
+
+	sb.WriteByte('"')
+
+	for _, r := range value {
+		switch r {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case 0:
+			sb.WriteString(`\0`)
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		default:
+			if unicode.IsPrint(r) {
+				sb.WriteRune(r)
+			} else {
+				fmt.Fprintf(&sb, `\u{%x}`, r)
+			}
+		}
+	}
+
+	sb.WriteByte('"')
+
+	return sb.String()
+}
@@ -1,27 +1,96 @@
 package lexer
 
+import "fmt"
+
 type TokenType rune
 
 const (
-	TypeLParen   TokenType = '('
-	TypeRParen   TokenType = ')'
-	TypeComma    TokenType = ','
-	TypeAssign   TokenType = '='
-	TypePlus     TokenType = '+'
-	TypeMinus    TokenType = '-'
-	TypeAsterisk TokenType = '*'
-	TypeSlash    TokenType = '/'
-	TypeCaret    TokenType = '^'
-	TypeTilde    TokenType = '~'
-	TypeBang     TokenType = '!'
-	TypeQuestion TokenType = '?'
-	TypeColon    TokenType = ':'
-	TypeSemi     TokenType = ';'
-	TypeEOF      TokenType = -1
-	TypeName     TokenType = -2
-	TypeNumber   TokenType = -3
+	TypeLParen    TokenType = '('
+	TypeRParen    TokenType = ')'
+	TypeComma     TokenType = ','
+	TypeAssign    TokenType = '='
+	TypePlus      TokenType = '+'
+	TypeMinus     TokenType = '-'
+	TypeAsterisk  TokenType = '*'
+	TypeSlash     TokenType = '/'
+	TypeCaret     TokenType = '^'
+	TypeTilde     TokenType = '~'
+	TypeBang      TokenType = '!'
+	TypeQuestion  TokenType = '?'
+	TypeColon     TokenType = ':'
+	TypeSemi      TokenType = ';'
+	TypeLess      TokenType = '<'
+	TypeGreater   TokenType = '>'
+	TypePercent   TokenType = '%'
+	TypeBackslash TokenType = '\\'
+	TypeAmpersand TokenType = '&'
+	TypePipe      TokenType = '|'
+	TypeLBrace    TokenType = '{'
+	TypeRBrace    TokenType = '}'
+	TypeLBracket  TokenType = '['
+	TypeRBracket  TokenType = ']'
+	TypeDot       TokenType = '.'
+	TypeEOF       TokenType = -1
+	TypeName      TokenType = -2
+	TypeNumber    TokenType = -3
+	TypeIllegal   TokenType = -4
+	TypeString    TokenType = -12
+	// Keywords.
+	TypeInfixl   TokenType = -13
+	TypeInfixr   TokenType = -14
+	TypeIf       TokenType = -18
+	TypeElse     TokenType = -19
+	TypeWhile    TokenType = -20
+	TypeFor      TokenType = -21
+	TypeIn       TokenType = -22
+	TypeBreak    TokenType = -23
+	TypeContinue TokenType = -24
+	// TypeUnderscore is the placeholder "_" in pipelines.
+	TypeUnderscore TokenType = -31
+	// TypeOperator is an operator declared in a script, the token's text is
+	// its spelling.
+	TypeOperator TokenType = -32
+	// Multi-character operators.
+	TypeLessEqual    TokenType = -5
+	TypeGreaterEqual TokenType = -6
+	TypeEqual        TokenType = -7
+	TypeNotEqual     TokenType = -8
+	TypeLogicalAnd   TokenType = -9
+	TypeLogicalOr    TokenType = -10
+	TypeArrow        TokenType = -11
+	TypeShiftLeft    TokenType = -15
+	TypeShiftRight   TokenType = -16
+	TypeDotDot       TokenType = -17
+	// Compound assignment operators.
+	TypePlusAssign     TokenType = -25
+	TypeMinusAssign    TokenType = -26
+	TypeAsteriskAssign TokenType = -27
+	TypeSlashAssign    TokenType = -28
+	TypeCaretAssign    TokenType = -29
+	TypePipeline       TokenType = -30
 )
 
+// multiCharPunctuators holds the spelling of the punctuators that don't fit
+// in a single rune.
+var multiCharPunctuators = map[TokenType]string{
+	TypeLessEqual:      "<=",
+	TypeGreaterEqual:   ">=",
+	TypeEqual:          "==",
+	TypeNotEqual:       "!=",
+	TypeLogicalAnd:     "&&",
+	TypeLogicalOr:      "||",
+	TypeArrow:          "=>",
+	TypeShiftLeft:      "<<",
+	TypeShiftRight:     ">>",
+	TypeDotDot:         "..",
+	TypePlusAssign:     "+=",
+	TypeMinusAssign:    "-=",
+	TypeAsteriskAssign: "*=",
+	TypeSlashAssign:    "/=",
+	TypeCaretAssign:    "^=",
+	TypePipeline:       "|>",
+}
+
 func TokenTypes() []TokenType {
 	return []TokenType{
 		TypeLParen,
@@ -38,15 +107,192 @@ func TokenTypes() []TokenType {
 		TypeQuestion,
 		TypeColon,
 		TypeSemi,
+		TypeLess,
+		TypeGreater,
+		TypePercent,
+		TypeBackslash,
+		TypeAmpersand,
+		TypePipe,
+		TypeLBrace,
+		TypeRBrace,
+		TypeLBracket,
+		TypeRBracket,
+		TypeDot,
 		TypeEOF,
 		TypeName,
 		TypeNumber,
+		TypeIllegal,
+		TypeString,
+		TypeLessEqual,
+		TypeGreaterEqual,
+		TypeEqual,
+		TypeNotEqual,
+		TypeLogicalAnd,
+		TypeLogicalOr,
+		TypeArrow,
+		TypeShiftLeft,
+		TypeShiftRight,
+		TypeDotDot,
+		TypePlusAssign,
+		TypeMinusAssign,
+		TypeAsteriskAssign,
+		TypeSlashAssign,
+		TypeCaretAssign,
+		TypePipeline,
+		TypeInfixl,
+		TypeInfixr,
+		TypeIf,
+		TypeElse,
+		TypeWhile,
+		TypeFor,
+		TypeIn,
+		TypeBreak,
+		TypeContinue,
+		TypeUnderscore,
+		TypeOperator,
 	}
 }
 
+// keywords are the names reserved by the language. A lone "_" is reserved too,
+// names can still contain underscores.
+var keywords = map[string]TokenType{
+	"infixl":   TypeInfixl,
+	"infixr":   TypeInfixr,
+	"if":       TypeIf,
+	"else":     TypeElse,
+	"while":    TypeWhile,
+	"for":      TypeFor,
+	"in":       TypeIn,
+	"break":    TypeBreak,
+	"continue": TypeContinue,
+	"_":        TypeUnderscore,
+}
+
+// punctuatorAliases are alternative spellings of punctuators, so formulas can
+// use the mathematical symbols.
+var punctuatorAliases = map[string]TokenType{
+	"×":  TypeAsterisk,
+	"·":  TypeAsterisk,
+	"÷":  TypeSlash,
+	"−":  TypeMinus,
+	"≤":  TypeLessEqual,
+	"≥":  TypeGreaterEqual,
+	"≠":  TypeNotEqual,
+	"∧":  TypeLogicalAnd,
+	"∨":  TypeLogicalOr,
+	"×=": TypeAsteriskAssign,
+	"·=": TypeAsteriskAssign,
+	"÷=": TypeSlashAssign,
+	"−=": TypeMinusAssign,
+}
+
+// Punctuator returns the source text of punctuator and operator token types,
+// or false for token types that don't have a fixed spelling.
+func (t TokenType) Punctuator() (string, bool) {
+	if t >= 0 {
+		return string(t), true
+	}
+
+	if text, ok := multiCharPunctuators[t]; ok {
+		return text, true
+	}
+
+	return operatorText(t)
+}
+
+// Keyword returns the source text of keyword token types, or false for other
+// token types.
+func (t TokenType) Keyword() (string, bool) {
+	for text, tokenType := range keywords {
+		if tokenType == t {
+			return text, true
+		}
+	}
+
+	return "", false
+}
+
+func (t TokenType) String() string {
+	if text, ok := t.Punctuator(); ok {
+		return text
+	}
+
+	if text, ok := t.Keyword(); ok {
+		return text
+	}
+
+	switch t {
+	case TypeEOF:
+		return "EOF"
+	case TypeName:
+		return "name"
+	case TypeNumber:
+		return "number"
+	case TypeIllegal:
+		return "illegal"
+	case TypeString:
+		return "string"
+	case TypeOperator:
+		return "operator"
+	default:
+		return "unknown"
+	}
+}
+
+// Position is a location in the source text. Offset is the zero-based byte
+// offset, Line and Column are one-based. Columns count characters, not bytes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the half-open source range [Start, End) covered by a token or an
+// expression.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
+}
+
+// To returns the span from the start of s to the end of other.
+func (s Span) To(other Span) Span {
+	return Span{Start: s.Start, End: other.End}
+}
+
+type TriviaKind int
+
+const (
+	TriviaWhitespace TriviaKind = iota
+	TriviaNewline
+	TriviaLineComment
+	TriviaBlockComment
+)
+
+// Trivia is source text that isn't significant to the parser, but is kept so
+// the source can be reproduced exactly.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Span Span
+}
+
 type Token struct {
 	Type TokenType
 	Text string
+	Span Span
+	// Err describes why the input was rejected, for TypeIllegal tokens.
+	Err error
+	// Leading and Trailing hold the trivia before and after the token.
+	Leading  []Trivia
+	Trailing []Trivia
 }
 
 func NewToken(t TokenType, text ...string) Token {
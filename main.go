@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 
@@ -12,22 +13,21 @@ import (
 
 func main() {
 	if len(os.Args) != 2 {
-		log.Fatal("usage: bantamgo <input>")
+		log.Fatal("usage: bantamgo <input>|-")
 	}
 
-	input := os.Args[1]
+	var lex *lexer.Lexer
 
-	log.Println("input:", input)
+	// "-" streams the input from stdin.
+	if input := os.Args[1]; input == "-" {
+		lex = lexer.NewReader(os.Stdin)
+	} else {
+		log.Println("input:", input)
 
-	// TODO(daniel): support defining functions, so the built-in `pow` can
-	// be replaced with something like `pow = (x, y) => x^y` (syntax tbd).
-	// TODO(daniel): support comparison operators ('<=', '>=', '==', '!=', etc.)
-	// This will require changes to the lexer to distinguish them from the
-	// existing single-character operators (e.g. '=' vs '==' and '!' vs '!=').
-	// Alternatively we could use 'eq', 'ne', 'lt', 'le', 'gt', 'ge' or similar
-	// and punt it to the parser, though that's probably a bad idea.
-	lexer := lexer.New(input)
-	parser := parser.New(lexer)
+		lex = lexer.New(input)
+	}
+
+	parser := parser.New(lex)
 
 	expr, err := parser.ParseExpression()
 	if err != nil {
@@ -49,8 +49,21 @@ func main() {
 	// TODO: type-checking
 
 	eval := evaluator.New()
-	expr.Visit(eval)
-	log.Println("answer:", eval.Answer())
+
+	answer, err := eval.Eval(expr)
+	if err != nil {
+		var failure *evaluator.RuntimeError
+
+		if errors.As(err, &failure) {
+			for _, frame := range failure.Stack {
+				log.Printf("in %s called at %v", frame.Name, frame.Span.Start)
+			}
+		}
+
+		log.Fatal(err)
+	}
+
+	log.Println("answer:", answer)
 
 	// TODO: codegen?
 }
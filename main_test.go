@@ -1,8 +1,15 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/corani/bantamgo/ast"
+	"github.com/corani/bantamgo/evaluator"
 	"github.com/corani/bantamgo/lexer"
 	"github.com/corani/bantamgo/parser"
 	"github.com/corani/bantamgo/printer"
@@ -43,6 +50,25 @@ func Test(t *testing.T) {
 		{"a * b / c", "((a * b) / c)"},
 		{"a ^ b ^ c", "(a ^ (b ^ c))"},
 
+		// Comparison operators.
+		{"a < b", "(a < b)"},
+		{"a<=b", "(a <= b)"},
+		{"a!=b", "(a != b)"},
+		{"a! == b", "((a!) == b)"},
+		{"a != !b", "(a != (!b))"},
+		{"a + b >= c * d", "((a + b) >= (c * d))"},
+		{"a < b == c > d", "(((a < b) == c) > d)"},
+		{"a = b == c", "(a = (b == c))"},
+		{"a < b ? c : d", "((a < b) ? c : d)"},
+
+		// Logical operators.
+		{"a && b", "(a && b)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a || b || c", "((a || b) || c)"},
+		{"a < b && c == d", "((a < b) && (c == d))"},
+		{"a || b ? c : d", "((a || b) ? c : d)"},
+
 		// Conditional operator.
 		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
 		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
@@ -53,11 +79,200 @@ func Test(t *testing.T) {
 		{"a ^ (b + c)", "(a ^ (b + c))"},
 		{"(!a)!", "((!a)!)"},
 
+		// Lambdas.
+		{"() => 1", "(() => 1)"},
+		{"(x, y) => x ^ y", "((x, y) => (x ^ y))"},
+		{"(x) => (y) => x + y", "((x) => ((y) => (x + y)))"},
+		{"f = (x) => x * 2", "(f = ((x) => (x * 2)))"},
+		{"((x) => x)(1)", "((x) => x)(1)"},
+		{"(a) + (b)", "(a + b)"},
+		{"(a) => (b)", "((a) => b)"},
+
+		// Strings.
+		{`"abc"`, `"abc"`},
+		{`"a" + "b"`, `("a" + "b")`},
+		{`"a\tb\n\"c\" \\ \u{1F600}\u{e9}"`, `"a\tb\n\"c\" \\ 😀é"`},
+		{`f("x", "y")`, `f("x", "y")`},
+
+		// Numbers.
+		{"1_000", "1_000"},
+		{"1.5", "1.5"},
+		{"0xff + 0XA", "(0xff + 0XA)"},
+		{"0b1010 - 0o17", "(0b1010 - 0o17)"},
+		{"1e3", "1e3"},
+		{"1.5e-3", "1.5e-3"},
+		{"2.0 * 3", "(2.0 * 3)"},
+		{"1e3-2", "(1e3 - 2)"},
+
+		// Unicode.
+		{"π = 3.14; 2 × π", "(π = 3.14); (2 * π)"},
+		{"Δx ≤ 1 ∧ y ≠ 2 ∨ z ≥ 3", "(((Δx <= 1) && (y != 2)) || (z >= 3))"},
+		{"a − b · c ÷ d", "(a - ((b * c) / d))"},
+		{"größe_2 = 1", "(größe_2 = 1)"},
+		{`"π"`, `"π"`},
+
+		// Comments.
+		{"a // comment\nb", "a; // comment\nb"},
+		{"a /* comment */ + b", "(a /* comment */ + b)"},
+		{"a / /* comment */ b", "(a / /* comment */ b)"},
+		{"/* leading */ a /* trailing */", "/* leading */ a /* trailing */"},
+
+		// Integer and bitwise operators
+		{"a % b * c \\ d", "(((a % b) * c) \\ d)"},
+		{"a + b & c", "(a + (b & c))"},
+		{"a | b & c ~ d", "((a | (b & c)) ~ d)"},
+		{"1 << n - 1", "((1 << n) - 1)"},
+		{"a >> 2 == b", "((a >> 2) == b)"},
+		{"~a ~ ~b", "((~a) ~ (~b))"},
+		{"a && b & c || d | e", "((a && (b & c)) || (d | e))"},
+		// Control flow
+		{"if a { b }", "if a { b }"},
+		{"if a { b; c } else { d }", "if a { b; c } else { d }"},
+		{"if a {} else if b { c } else { d }", "if a {} else if b { c } else { d }"},
+		{"x = if a < b { a } else { b }", "(x = if (a < b) { a } else { b })"},
+		{"while i < n { i = i + 1 }", "while (i < n) { (i = (i + 1)) }"},
+		{"for i in 0..n - 1 { f(i) }", "for i in 0..(n - 1) { f(i) }"},
+		{"for i in a..b { if i { continue }; break }", "for i in a..b { if i { continue }; break }"},
+		{"while a { (x) => x }", "while a { ((x) => x) }"},
+		// Lists
+		{"[]", "[]"},
+		{"[1, a + b, [c]]", "[1, (a + b), [c]]"},
+		{"xs[i + 1]", "xs[(i + 1)]"},
+		{"xs[1][2]", "xs[1][2]"},
+		{"f(x)[0]", "f(x)[0]"},
+		{"-xs[0]", "(-xs[0])"},
+		{"xs[a:b]; xs[:b]; xs[a:]; xs[:]", "xs[a:b]; xs[:b]; xs[a:]; xs[:]"},
+		{"xs[a ? b : c]", "xs[(a ? b : c)]"},
+		{"[1, 2][0]", "[1, 2][0]"},
+		// Records
+		{"{qty: 2, price: a * b}", "{qty: 2, price: (a * b)}"},
+		{"x = {:}; {}; {:} = x", "(x = {:}); {}; ({:} = x)"},
+		{"order.qty * order.price", "(order.qty * order.price)"},
+		{"a.b.c", "a.b.c"},
+		{"f(x).y[0].z", "f(x).y[0].z"},
+		{"-a.b!", "(-(a.b!))"},
+		{"{a: {b: 1}}.a.b", "{a: {b: 1}}.a.b"},
+		{"{ a ? b : c }", "{ (a ? b : c) }"},
+		// Tuples and destructuring
+		{"(a, b)", "(a, b)"},
+		{"(a, b) = (b, a)", "((a, b) = (b, a))"},
+		{"[x, y] = point", "([x, y] = point)"},
+		{"{qty, price} = order", "({qty, price} = order)"},
+		{"{qty: q, pos: [x, y]} = order", "({qty: q, pos: [x, y]} = order)"},
+		{"{qty} = order", "({qty} = order)"},
+		{"{qty, price}", "{qty: qty, price: price}"},
+		{"(a, [b, c]) = (x, y) = z", "((a, [b, c]) = ((x, y) = z))"},
+		{"{ qty }", "{ qty }"},
+		// Compound assignment
+		{"total += x", "(total = (total + x))"},
+		{"a -= b * c", "(a = (a - (b * c)))"},
+		{"a *= b += c", "(a = (a * (b = (b + c))))"},
+		{"a /= 2; b ^= 2", "(a = (a / 2)); (b = (b ^ 2))"},
+		{"a ×= 2; b −= 1", "(a = (a * 2)); (b = (b - 1))"},
+		{"x = a-=1", "(x = (a = (a - 1)))"},
+		// Pipelines
+		{"x |> f", "f(x)"},
+		{"x |> abs |> sqrt |> round(_, 2)", "round(sqrt(abs(x)), 2)"},
+		{"x |> f(1)", "f(1)(x)"},
+		{"x |> f(1, _)", "f(1, x)"},
+		{"a + b |> f", "f((a + b))"},
+		{"y = x |> f", "(y = f(x))"},
+		{"x |> (a) => a * 2", "((a) => (a * 2))(x)"},
+		{"a ? b : c |> f", "f((a ? b : c))"},
+		{"x |> f(y |> g(_), _)", "f(g(y), x)"},
+		{"my_x |> f(_)", "f(my_x)"},
+		// Named arguments and defaults
+		{"round(x, digits: 2)", "round(x, digits: 2)"},
+		{"f(a: b ? c : d, e: {f: g})", "f(a: (b ? c : d), e: {f: g})"},
+		{"f = (a, b = 2, c = a + b) => c", "(f = ((a, b = 2, c = (a + b)) => c))"},
+		{"f = (a, b = { t = 1; t }, c = [1, 2]) => a", "(f = ((a, b = { (t = 1); t }, c = [1, 2]) => a))"},
+		{"x |> round(digits: 2, x: _)", "round(digits: 2, x: x)"},
+		// Nested blocks
+		{"x = { t = a * 2; t + 1 }", "(x = { (t = (a * 2)); (t + 1) })"},
+		{"{ a; { b } }; {}", "{ a; { b } }; {}"},
+		{"f = () => { a; b }", "(f = (() => { a; b }))"},
+		{"{ a } + 1", "({ a } + 1)"},
+		// Operator declarations
+		{"infixl 6 <+> = f; a <+> b * c <+> d", "infixl 6 <+> = f; ((a <+> (b * c)) <+> d)"},
+		{"infixr 8 ** = pow; a ** b ** c", "infixr 8 ** = pow; (a ** (b ** c))"},
+		{"infixl 5 &> = (x, f) => f(x); a &> f == b", "infixl 5 &> = ((x, f) => f(x)); ((a &> f) == b)"},
+		{"infixl 2 <|> = f; a <|> b |> g", "infixl 2 <|> = f; g((a <|> b))"},
 		// Blocks (semi-colons are optional)
 		{"a b c", "a; b; c"},
 		{"a; b c;", "a; b; c"},
 	}
 
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			// The printed form parses to the same expression.
+			for _, in := range []string{tc.in, tc.out} {
+				expr, err := parser.New(lexer.New(in)).ParseExpression()
+				rq.NoError(err)
+
+				pprint := printer.Printer()
+				expr.Visit(pprint)
+
+				rq.Equal(tc.out, pprint.String())
+			}
+		})
+	}
+}
+
+func TestGrammar(t *testing.T) {
+	t.Parallel()
+
+	grammar := parser.DefaultGrammar()
+
+	_, err := grammar.Infix("@", parser.PrecProduct, parser.AssocLeft)
+	require.NoError(t, err)
+
+	_, err = grammar.Infix("<>", parser.PrecComparison, parser.AssocLeft)
+	require.NoError(t, err)
+
+	_, err = grammar.Infix("**", parser.PrecExponent, parser.AssocRight)
+	require.NoError(t, err)
+
+	_, err = grammar.Prefix("√", parser.PrecPrefix)
+	require.NoError(t, err)
+
+	_, err = grammar.Postfix("%%", parser.PrecPostfix)
+	require.NoError(t, err)
+
+	// "..." builds a call to "range" with a custom parselet.
+	rangeType, err := lexer.Operator("...")
+	require.NoError(t, err)
+
+	grammar.RegisterInfix(rangeType, parser.NewInfixParselet(parser.PrecSum-1,
+		func(p *parser.Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			right, err := p.Parse(parser.PrecSum - 1)
+			if err != nil {
+				return nil, err
+			}
+
+			callee := ast.NameExpression(t.Span, "range")
+
+			return ast.CallExpression(left.Span().To(right.Span()), callee, []ast.Expression{left, right}, nil), nil
+		}))
+
+	tt := []struct {
+		in, out string
+	}{
+		{"a @ b * c", "((a @ b) * c)"},
+		{"a + b @ c", "(a + (b @ c))"},
+		{"a <> b < c", "((a <> b) < c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a ** b ^ c", "(a ** (b ^ c))"},
+		{"√a * b", "((√a) * b)"},
+		{"a%%%b", "((a%%) % b)"},
+		{"1 ... n + 1", "range(1, (n + 1))"},
+		{"a...b == c", "(range(a, b) == c)"},
+		{"for i in 0..n { a...i }", "for i in 0..n { range(a, i) }"},
+	}
+
 	for _, tc := range tt {
 		t.Run(tc.in, func(t *testing.T) {
 			t.Parallel()
@@ -65,7 +280,7 @@ func Test(t *testing.T) {
 			rq := require.New(t)
 
 			lexer := lexer.New(tc.in)
-			parser := parser.New(lexer)
+			parser := parser.NewWithGrammar(lexer, grammar)
 			pprint := printer.Printer()
 
 			expr, err := parser.ParseExpression()
@@ -77,3 +292,802 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestGrammarErrors(t *testing.T) {
+	t.Parallel()
+
+	rq := require.New(t)
+
+	grammar := parser.DefaultGrammar()
+
+	for _, text := range []string{"", "a+", "1%", "(+)", "//", "+/*"} {
+		_, err := grammar.Infix(text, parser.PrecSum, parser.AssocLeft)
+		rq.EqualError(err, fmt.Sprintf("invalid operator %q", text))
+	}
+
+	// Spellings with a built-in token type are reserved.
+	for _, text := range []string{"+", ":", "..", "=>", "|>", "×"} {
+		_, err := grammar.Infix(text, parser.PrecSum, parser.AssocLeft)
+		rq.EqualError(err, fmt.Sprintf("operator %q is reserved", text))
+
+		_, err = grammar.Prefix(text, parser.PrecPrefix)
+		rq.EqualError(err, fmt.Sprintf("operator %q is reserved", text))
+	}
+
+	// Operators registered on another grammar aren't known by default.
+	extended := grammar.Clone()
+
+	_, err := extended.Infix("@", parser.PrecProduct, parser.AssocLeft)
+	rq.NoError(err)
+
+	_, err = parser.New(lexer.New("a @ b")).ParseExpression()
+	rq.EqualError(err, "1:3: error: illegal character '@'")
+
+	_, err = parser.NewWithGrammar(lexer.New("a @ b"), grammar).ParseExpression()
+	rq.EqualError(err, "1:3: error: illegal character '@'")
+
+	_, err = parser.NewWithGrammar(lexer.New("a @ b"), extended).ParseExpression()
+	rq.NoError(err)
+
+	// Scripts can't redefine the operators of the grammar.
+	_, err = extended.Prefix("√", parser.PrecPrefix)
+	rq.NoError(err)
+
+	_, err = parser.NewWithGrammar(lexer.New("infixl 4 @ = f; infixl 4 √ = f"), extended).ParseExpression()
+	rq.EqualError(err, "1:10: error: operator \"@\" is already defined\n1:26: error: operator \"√\" is already defined")
+}
+
+func TestSpans(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in, out string
+	}{
+		{"a", "1:1-1:2"},
+		{"a + bc", "1:1-1:7"},
+		{"-a!", "1:1-1:4"},
+		{"f(a, b)", "1:1-1:8"},
+		{"a = b ? c : d", "1:1-1:14"},
+		{"a +\n  b", "1:1-2:4"},
+		{"\n\n  a()", "3:3-3:6"},
+		{"π × Δx", "1:1-1:7"},
+		{"/* ü */ ñ", "1:9-1:10"},
+		{"if a { b } else { c }", "1:1-1:22"},
+		{"for i in 0..2 {\n}", "1:1-2:2"},
+		{"{ a; b }", "1:1-1:9"},
+		{"[a, b]", "1:1-1:7"},
+		{"xs[1:2]", "1:1-1:8"},
+		{"{a: 1}", "1:1-1:7"},
+		{"a.bc", "1:1-1:5"},
+		{"(a, b)", "1:1-1:7"},
+		{"(a, b) = c", "1:1-1:11"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			lexer := lexer.New(tc.in)
+			parser := parser.New(lexer)
+
+			expr, err := parser.ParseExpression()
+			rq.NoError(err)
+
+			block, ok := expr.(*ast.BlockExpressionNode)
+			rq.True(ok)
+			rq.Len(block.Expressions, 1)
+
+			rq.Equal(tc.out, block.Expressions[0].Span().String())
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in, out string
+		errs    []string
+	}{
+		{"a +; b", "b", []string{
+			`1:4: error: unexpected ";"`,
+		}},
+		{"f(a b); c", "c", []string{
+			`1:5: error: expected ")" but found "b"`,
+		}},
+		{"a ? b; c", "c", []string{
+			`1:6: error: expected ":" but found ";"`,
+		}},
+		{"(a; b = 1; 1 = c; d)", "(b = 1); d", []string{
+			`1:3: error: expected ")" but found ";"`,
+			`1:12: error: the left-hand side of an assignment must be a name or a pattern`,
+			`1:20: error: unexpected ")"`,
+		}},
+		{"a * (b", "", []string{
+			`1:7: error: expected ")" but found end of input`,
+		}},
+		{"(x, 1) => x; y", "y", []string{
+			`1:5: error: expected "name" but found "1"`,
+		}},
+		{"(x, x) => x", "", []string{
+			`1:5: error: duplicate parameter "x"`,
+		}},
+		{"f = (a, a) => { a; (a) }; g = (b) => [b]; h = )", "(g = ((b) => [b]))", []string{
+			`1:9: error: duplicate parameter "a"`,
+			`1:47: error: unexpected ")"`,
+		}},
+		{`a = "abc; b`, "", []string{
+			`1:5: error: unterminated string`,
+		}},
+		{`"a\qb"; c`, "c", []string{
+			`1:1: error: invalid escape sequence "\\q"`,
+		}},
+		{`"\u{110000}"; "\u{}"`, "", []string{
+			`1:1: error: invalid unicode code point "110000"`,
+			`1:15: error: invalid unicode escape, expected \u{...} with 1 to 6 hex digits`,
+		}},
+		{"a; /* comment", "a", []string{
+			`1:4: error: unterminated block comment`,
+		}},
+		{"1.2.3; 0x; 1e; 1__0; 1_; 1_.5; 12abc; 0b102; 0x1p4; 1.5e+", "", []string{
+			`1:1: error: malformed number "1.2.3"`,
+			`1:8: error: malformed number "0x"`,
+			`1:12: error: malformed number "1e"`,
+			`1:16: error: malformed number "1__0"`,
+			`1:22: error: malformed number "1_"`,
+			`1:26: error: malformed number "1_.5"`,
+			`1:32: error: malformed number "12abc"`,
+			`1:39: error: malformed number "0b102"`,
+			`1:46: error: malformed number "0x1p4"`,
+			`1:53: error: malformed number "1.5e+"`,
+		}},
+		{"99999999999999999999; 1e999", "", []string{
+			`1:1: error: number "99999999999999999999" out of range`,
+			`1:23: error: number "1e999" out of range`,
+		}},
+		{"2π; a € b; c", "a; c", []string{
+			`1:1: error: malformed number "2π"`,
+			`1:7: error: illegal character '€'`,
+		}},
+		{"a $ b; c", "a; c", []string{
+			`1:3: error: illegal character '$'`,
+		}},
+		{"f(a @)", "", []string{
+			`1:5: error: illegal character '@'`,
+		}},
+		{"break; f = () => continue; g()", "g()", []string{
+			`1:1: error: break outside of a loop`,
+			`1:18: error: continue outside of a loop`,
+		}},
+		{"while a { break }; for i in 0..10 { f = () => break }", "while a { break }; for i in 0..10 {}", []string{
+			`1:47: error: break outside of a loop`,
+		}},
+		{"f = 0; while true { f = (a = break) => a; break }; f()", "(f = 0); while true { break }; f()", []string{
+			`1:30: error: break outside of a loop`,
+		}},
+		{"if a { b +; c; } d", "if a { c }; d", []string{
+			`1:11: error: unexpected ";"`,
+		}},
+		{"x = { a +; b } c", "(x = { b }); c", []string{
+			`1:10: error: unexpected ";"`,
+		}},
+		{"[1, 2; xs[1 2]; xs[1:2 3]; ys", "ys", []string{
+			`1:6: error: expected "]" but found ";"`,
+			`1:13: error: expected "]" or ":" but found "2"`,
+			`1:24: error: expected "]" but found "3"`,
+		}},
+		{"{a: 1, a: 2}; {a: 1, 2}; {a: 1 b: 2}; a.1; x", "x", []string{
+			`1:8: error: duplicate field "a"`,
+			`1:22: error: expected "name" but found "2"`,
+			`1:32: error: expected "}" but found "b"`,
+			`1:41: error: expected "name" but found "1"`,
+		}},
+		{"{:, a}; {: 1}; x", "x", []string{
+			`1:3: error: expected "}" but found ","`,
+			`1:12: error: expected "}" but found "1"`,
+		}},
+		{"(a, 1) = x; [a, a] = x; {a, b: a} = x; [o.a] = x; f(x) = 1; y", "y", []string{
+			`1:5: error: expected a name or a pattern`,
+			`1:17: error: duplicate name "a" in pattern`,
+			`1:32: error: duplicate name "a" in pattern`,
+			`1:41: error: expected a name or a pattern`,
+			`1:51: error: the left-hand side of an assignment must be a name or a pattern`,
+		}},
+		{"1 += 2; (a, b) += c; f(x) -= 1; y", "y", []string{
+			`1:1: error: the left-hand side of "+=" must be a name`,
+			`1:9: error: the left-hand side of "+=" must be a name`,
+			`1:22: error: the left-hand side of "-=" must be a name`,
+		}},
+		{"x |> f(_, _); x |> ; y", "y", []string{
+			`1:11: error: only one placeholder is allowed in a pipeline`,
+			`1:20: error: unexpected ";"`,
+		}},
+		{"1 |> f(g(_)); x |> _; 1 |> f(_)(2); x |> f(_).y; _ = 3; f(_); (_) => 1; y", "y", []string{
+			`1:10: error: "_" is only allowed as an argument of the call after "|>"`,
+			`1:20: error: "_" is only allowed as an argument of the call after "|>"`,
+			`1:30: error: "_" is only allowed as an argument of the call after "|>"`,
+			`1:44: error: "_" is only allowed as an argument of the call after "|>"`,
+			`1:50: error: "_" is only allowed as an argument of the call after "|>"`,
+			`1:59: error: "_" is only allowed as an argument of the call after "|>"`,
+			`1:64: error: expected "name" but found "_"`,
+		}},
+		{"f(a: 1, 2); f(a: 1, a: 2); (a = 1, b) => a; y", "y", []string{
+			`1:9: error: positional argument after a named argument`,
+			`1:21: error: duplicate argument "a"`,
+			`1:36: error: parameter "b" without a default follows a parameter with one`,
+		}},
+		{"{ a; b", "", []string{
+			`1:7: error: expected "}" but found end of input`,
+		}},
+		{"a }; b", "a; b", []string{
+			`1:3: error: unexpected "}"`,
+		}},
+		{"if a { b", "", []string{
+			`1:9: error: expected "}" but found end of input`,
+		}},
+		{"for i 0..1 {}; for i in 0, 1 {}; x }", "x", []string{
+			`1:7: error: expected "in" but found "0"`,
+			`1:26: error: expected ".." but found ","`,
+			`1:36: error: unexpected "}"`,
+		}},
+		{"infixl x <+> = f; infixl 12 <+> = f; infixl 4 + = f; infixl 4 <+>= f; infixl 4 a = f", "", []string{
+			`1:8: error: expected "number" but found "x"`,
+			`1:26: error: invalid precedence "12", expected an integer between 1 and 11`,
+			`1:47: error: operator "+" is reserved`,
+			`1:68: error: expected "=" but found "f"`,
+			`1:80: error: expected an operator but found "a"`,
+		}},
+		{"f = () => { infixl 7 <+> = g; 1 }; if a { infixr 7 <-> = g }; x = infixl 7 <*> = g; 1 <+> 2", "(f = (() => { 1 })); if a {}; (1 <+> 2)", []string{
+			`1:13: error: operator declarations are only allowed at the top level`,
+			`1:43: error: operator declarations are only allowed at the top level`,
+			`1:67: error: operator declarations are only allowed at the top level`,
+		}},
+		{"a <-b; infixl 4 <- = f; infixl 6 +-- = f; infixl 6 <+> = f; infixl 6 <+> = g; infixl 6 <+>- = f; a <-b", "(a < (-b)); infixl 6 <+> = f; (a < (-b))", []string{
+			`1:17: error: operator "<-" would change the meaning of "a < -b"`,
+			`1:34: error: operator "+--" would change the meaning of "a + --b"`,
+			`1:70: error: operator "<+>" is already defined`,
+			`1:88: error: operator "<+>-" would change the meaning of "a <+> -b"`,
+		}},
+		{"infixl 4 : = f; infixl 4 => = f; infixl 4 .. = f; a ? b : c", "(a ? b : c)", []string{
+			`1:10: error: operator ":" is reserved`,
+			`1:26: error: operator "=>" is reserved`,
+			`1:43: error: operator ".." is reserved`,
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			lexer := lexer.New(tc.in)
+			parser := parser.New(lexer)
+			pprint := printer.Printer()
+
+			expr, err := parser.ParseExpression()
+			rq.Error(err)
+
+			expr.Visit(pprint)
+
+			rq.Equal(tc.out, pprint.String())
+
+			var messages []string
+
+			for _, diag := range parser.Diagnostics() {
+				messages = append(messages, diag.Error())
+			}
+
+			rq.Equal(tc.errs, messages)
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in, out string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"pow(2, 10)", "1024.0"},
+		{"a = 2; b = 3; a * b", "6"},
+		{"a = b = 2; a + b", "4"},
+		{"1 < 2", "true"},
+		{"2 <= 1", "false"},
+		{"3 == 3", "true"},
+		{"3 != 3", "false"},
+		{"2 > 1 ? 10 : 20", "10"},
+		{"1 >= 2 ? 10 : 20", "20"},
+		{"1 && 2", "true"},
+		{"1 && 0", "false"},
+		{"0 || 2", "true"},
+		{"0 || 0", "false"},
+		{"a = 1; 0 && (a = 2); a", "1"},
+		{"a = 1; 1 || (a = 2); a", "1"},
+		{"a = 1; 1 && (a = 2); a", "2"},
+		{"0 && undefined", "false"},
+		{"square = (x) => x * x; square(3)", "9"},
+		{"pow = (x, y) => x ^ y; pow(2, 3)", "8"},
+		{"add = (x) => (y) => x + y; add(1)(2)", "3"},
+		{"fact = (n) => n < 2 ? 1 : n * fact(n - 1); fact(5)", "120"},
+		{"twice = (f, x) => f(f(x)); twice((x) => x + 1, 1)", "3"},
+		{"x = 1; f = (x) => x * 10; f(2) + x", "21"},
+		{"x = 1; f = () => x = 2; f() + x", "3"},
+		{"x = 1; f = () => x; x = 5; f()", "5"},
+		{"pow = (x, y) => x * y; pow(2, 3)", "6"},
+		{"pow", "<function>"},
+		{"", "nil"},
+		{"7 / 2", "3.5"},
+		{"6 / 2", "3.0"},
+		{"1.5 + 1", "2.5"},
+		{"2 ^ 10", "1024"},
+		{"2 ^ -1", "0.5"},
+		{"[2 ^ 62, (-2) ^ 63, 1 ^ 100, (-1) ^ 101, 3037000499 ^ 2]", "[4611686018427387904, -9223372036854775808, 1, -1, 9223372030926249001]"},
+		{"[9223372036854775807 + -1, -9223372036854775807 - 1, -3 * 3074457345618258602]", "[9223372036854775806, -9223372036854775808, -9223372036854775806]"},
+		{"-3 * 2", "-6"},
+		{"~5", "-6"},
+		{"5!", "120"},
+		{"!0", "true"},
+		{"!nil", "true"},
+		{"!0.5", "false"},
+		{"0.5 ? 1 : 2", "1"},
+		{"1 == 1.0", "true"},
+		{"true == 1", "false"},
+		{"nil == nil", "true"},
+		{"pow == pow", "false"},
+		{"f = () => 1; f == f", "true"},
+		{"2 < 2.5", "true"},
+		{"9007199254740993 == 9007199254740992.0", "false"},
+		{"9007199254740993 > 9007199254740992.0", "true"},
+		{"9223372036854775807 < 9223372036854775807.0", "true"},
+		{"-2.5 < -2", "true"},
+		{"nan = 1e308 * 10; nan = nan - nan; [nan < 1, nan >= 1, nan == nan]", "[false, false, false]"},
+		{"true && nil", "false"},
+		{"π = 3; 6 ÷ π", "2.0"},
+		{"x = 5 − 2; x × x", "9"},
+		{"0xff + 1", "256"},
+		{"0b1010 - 0o17", "-5"},
+		{"1e3", "1000.0"},
+		{"1.5e-3", "0.0015"},
+		{"1e-9", "1e-09"},
+		{"2E+2", "200.0"},
+		{"1_0.2_5e1_0", "1.025e+11"},
+		{"1_000 * 2", "2000"},
+		{`"a" + "b"`, "ab"},
+		{`"n = " + 1 + ", x = " + 1.0`, "n = 1, x = 1.0"},
+		{`"n = " + 1.5`, "n = 1.5"},
+		{`1 + "x"`, "1x"},
+		{`"a" < "b"`, "true"},
+		{`"b" <= "a"`, "false"},
+		{`"a" == "a"`, "true"},
+		{`"1" == 1`, "false"},
+		{`"" ? 1 : 2`, "2"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7 % -3", "1"},
+		{"7 \\ 2", "3"},
+		{"-7 \\ 2", "-3"},
+		{"-7 \\ 2 * 2 + -7 % 2", "-7"},
+		{"12 & 10", "8"},
+		{"12 | 10", "14"},
+		{"12 ~ 10", "6"},
+		{"~0 ~ 5", "-6"},
+		{"1 << 10", "1024"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"0 << 64", "0"},
+		{"1 >> 64", "0"},
+		{"-16 >> 2", "-4"},
+		{"1 + 2 & 3", "3"},
+		{"[]", "[]"},
+		{`[1, 2.5, "a", [nil, true]]`, `[1, 2.5, "a", [nil, true]]`},
+		{"xs = [10, 20, 30]; xs[0] + xs[2]", "40"},
+		{"[[1, 2], [3, 4]][1][0]", "3"},
+		{"xs = [1, 2, 3, 4]; xs[1:3]", "[2, 3]"},
+		{"xs = [1, 2, 3, 4]; [xs[:1], xs[3:], xs[:], xs[2:2]]", "[[1], [4], [1, 2, 3, 4], []]"},
+		{"[1, 2] == [1, 2.0]", "true"},
+		{"[1, 2] == [1, 2, 3]", "false"},
+		{"[] ? 1 : 2", "2"},
+		{`[len([1, 2]), len(""), len("héllo")]`, "[2, 0, 5]"},
+		{"xs = [3, 1, 2]; sum = 0; for i in 0..len(xs) { sum = sum + xs[i] }; sum", "6"},
+		{"{qty: 2, price: 1.5}", "{price: 1.5, qty: 2}"},
+		{"r = {:}; [r, r == {:}, {} == nil]", "[{:}, true, true]"},
+		{`{name: "x", tags: ["a"]}`, `{name: "x", tags: ["a"]}`},
+		{"order = {qty: 2, price: 1.5, discount: 0.5}; order.qty * order.price - order.discount", "2.5"},
+		{"{a: {b: [1, 2]}}.a.b[1]", "2"},
+		{"{a: 1, b: 2} == {b: 2, a: 1.0}", "true"},
+		{"{a: 1} == {a: 1, b: 2}", "false"},
+		{"point = (x, y) => {x: x, y: y}; point(1, 2).y", "2"},
+		{"(1, 2.5)", "[1, 2.5]"},
+		{"a = 1; b = 2; (a, b) = (b, a); [a, b]", "[2, 1]"},
+		{"[x, y] = [3, 4]; x * y", "12"},
+		{"order = {qty: 2, price: 1.5}; {qty, price} = order; qty * price", "3.0"},
+		{"{pos: [x, y], name: n} = {name: \"p\", pos: (1, 2)}; [n, x, y]", `["p", 1, 2]`},
+		{"{qty} = {qty: 3, price: 1}; qty", "3"},
+		{"([a, b], c) = ([1, 2], 3); a + b + c", "6"},
+		{"(a, b) = (1, 2)", "[1, 2]"},
+		{"f = () => { (x, y) = (1, 2); x + y }; f()", "3"},
+		{"x = 0; f = () => { (x, y) = (1, 2) }; f(); x", "0"},
+		{"x = 0; { (x, y) = (1, 2) }; x", "1"},
+		{"total = 0; for i in 1..5 { total += i }; total", "10"},
+		{"x = 10; x -= 3; x *= 2; x", "14"},
+		{"x = 1; x /= 4", "0.25"},
+		{"x = 3; x ^= 2", "9"},
+		{`s = "a"; s += 1; s`, `a1`},
+		{"a = 1; b = 2; a += b += 3; [a, b]", "[6, 5]"},
+		{"-2.25 |> abs |> sqrt", "1.5"},
+		{"x = -7; x |> abs", "7"},
+		{"2 |> sqrt |> round(_, 3)", "1.414"},
+		{"[round(2.5), round(-2.5), round(7), round(1234.5, -2)]", "[3.0, -3.0, 7, 1200.0]"},
+		{"[round(1234, -2), round(1250, -2), round(-1250, -2), round(1249, -2), round(7, 2)]", "[1200, 1300, -1300, 1200, 7]"},
+		{"[round(4999999999999999999, -19), round(-123, -25)]", "[0, 0]"},
+		{"add = (a) => (b) => a + b; 1 |> add(2)", "3"},
+		{"10 |> pow(2, _)", "1024.0"},
+		{"[1, 2, 3] |> len", "3"},
+		{"double = (x) => x * 2; 3 |> double |> double |> (x) => x + 1", "13"},
+		{"round(3.14159, digits: 2)", "3.14"},
+		{"round(digits: 1, x: 2.25)", "2.3"},
+		{"pow(y: 3, x: 2)", "8.0"},
+		{"f = (a, b = 10) => a + b; [f(1), f(1, 2), f(1, b: 3), f(a: 4)]", "[11, 3, 4, 14]"},
+		{"f = (a, b = a * 2) => [a, b]; f(3)", "[3, 6]"},
+		{"f = (a, b = { t = 1; t }) => a + b; f(1)", "2"},
+		{"b = 1; f = (a = b) => a; b = 2; f()", "2"},
+		{"3.14159 |> round(digits: 1, x: _)", "3.1"},
+		{"a = 3; x = { t = a * 2; t + 1 }; x", "7"},
+		{"{}", "nil"},
+		{"y = 1; { y = 2; z = 3 }; y", "2"},
+		{"f = (n) => { m = n * 2; m + 1 }; f(20)", "41"},
+		{"{ { 1 }; { 2 } } * 3", "6"},
+		{"if 1 < 2 { 3 } else { 4 }", "3"},
+		{"if nil { 3 }", "nil"},
+		{"x = 0; if x < 0 { -1 } else if x == 0 { 0.0 } else { 1 }", "0.0"},
+		{"if true { x = 1 }; x = 2; x", "2"},
+		{"i = 0; while i < 5 { i = i + 1 }", "5"},
+		{"while false { 1 }", "nil"},
+		{"sum = 0; for i in 0..5 { sum = sum + i }; sum", "10"},
+		{"for i in 5..0 { i }", "nil"},
+		{"for i in 0..3 { i * 10 }", "20"},
+		{"sum = 0; for i in 0..10 { if i % 2 == 0 { continue }; if i > 7 { break }; sum = sum + i }; sum", "16"},
+		{"i = 0; while true { i = i + 1; if i == 3 { break } }; i", "3"},
+		{"for i in 0..3 { i; break }", "nil"},
+		{"n = 0; for i in 0..3 { for j in 0..3 { if j > i { break }; n = n + 1 } }; n", "6"},
+		{"fs = 0; for i in 1..4 { f = () => i; fs = fs + f() }; fs", "6"},
+		{"for i in 0..1 { i = 5 }; i = 7; i", "7"},
+		{"infixl 6 <+> = (a, b) => a * 10 + b; 1 <+> 2 <+> 3", "123"},
+		{"infixr 8 ^^ = (a, b) => a ^ b; 2 ^^ 3 ^^ 2", "512"},
+		{"infixl 3 ?? = (a, b) => a == nil ? b : a; nil ?? 1 ?? 2", "1"},
+		{"infixr 6 <> = (a, b) => a + b; f = (x) => x <> \"!\"; f(\"hi\")", "hi!"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			lexer := lexer.New(tc.in)
+			parser := parser.New(lexer)
+			eval := evaluator.New()
+
+			expr, err := parser.ParseExpression()
+			rq.NoError(err)
+
+			answer, err := eval.Eval(expr)
+			rq.NoError(err)
+
+			rq.Equal(tc.out, answer.String())
+		})
+	}
+}
+
+func TestEvalInputs(t *testing.T) {
+	t.Parallel()
+
+	rq := require.New(t)
+
+	expr, err := parser.New(lexer.New("order.qty * order.price * (1 - order.discount)")).ParseExpression()
+	rq.NoError(err)
+
+	eval := evaluator.New()
+	eval.Define("order", evaluator.Record{
+		"qty":      evaluator.Int(4),
+		"price":    evaluator.Float(2.5),
+		"discount": evaluator.Float(0.1),
+	})
+
+	answer, err := eval.Eval(expr)
+	rq.NoError(err)
+	rq.Equal(evaluator.Float(9), answer)
+}
+
+func TestEvalErrors(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in, err string
+		kind    evaluator.ErrorKind
+		stack   []string
+	}{
+		{"undefined_var + 1", `1:1: undefined name "undefined_var"`, evaluator.ErrorKindUndefined, nil},
+		{"f = () => y = 2; f(); y", `1:23: undefined name "y"`, evaluator.ErrorKindUndefined, nil},
+		{"1 + pow", `1:5: expected a number, got function`, evaluator.ErrorKindType, nil},
+		{"1(2)", `1:1: expected a function, got int`, evaluator.ErrorKindType, nil},
+		{"pow(1)", `1:1: wrong number of arguments: expected 2, got 1`, evaluator.ErrorKindArity, []string{"pow"}},
+		{"f = (x) => x; f(1, 2)", `1:15: wrong number of arguments: expected 1, got 2`, evaluator.ErrorKindArity, []string{"f"}},
+		{"g = () => 1 + h; f = () => 2 * g(); f()", `1:15: undefined name "h"`, evaluator.ErrorKindUndefined, []string{"g", "f"}},
+		{"~1.5", `1:2: expected an int, got float`, evaluator.ErrorKindType, nil},
+		{"-true", `1:2: expected a number, got bool`, evaluator.ErrorKindType, nil},
+		{"1 + nil", `1:5: expected a number, got nil`, evaluator.ErrorKindType, nil},
+		{`"a" + nil`, `1:7: expected a string or a number, got nil`, evaluator.ErrorKindType, nil},
+		{`pow + "x"`, `1:1: expected a string or a number, got function`, evaluator.ErrorKindType, nil},
+		{`"x" + [1]`, `1:7: expected a string or a number, got list`, evaluator.ErrorKindType, nil},
+		{"1 < true", `1:5: expected a number, got bool`, evaluator.ErrorKindType, nil},
+		{"2.5!", `1:1: expected an int, got float`, evaluator.ErrorKindType, nil},
+		{"(0 - 1)!", `1:2: factorial of negative number -1`, evaluator.ErrorKindRange, nil},
+		{"21!", `1:1: factorial of 21 overflows`, evaluator.ErrorKindRange, nil},
+		{"9223372036854775807 + 1", `1:1: 9223372036854775807 + 1 overflows`, evaluator.ErrorKindRange, nil},
+		{"-9223372036854775807 - 2", `1:1: -9223372036854775807 - 2 overflows`, evaluator.ErrorKindRange, nil},
+		{"4294967296 * 4294967296", `1:1: 4294967296 * 4294967296 overflows`, evaluator.ErrorKindRange, nil},
+		{"2 ^ 64", `1:1: 2 ^ 64 overflows`, evaluator.ErrorKindRange, nil},
+		{"1 << 63", `1:1: 1 << 63 overflows`, evaluator.ErrorKindRange, nil},
+		{"1 << 64", `1:1: 1 << 64 overflows`, evaluator.ErrorKindRange, nil},
+		{"-3 << 62", `1:1: -3 << 62 overflows`, evaluator.ErrorKindRange, nil},
+		{"(-3) ^ 41", `1:2: -3 ^ 41 overflows`, evaluator.ErrorKindRange, nil},
+		{"x = -9223372036854775807 - 1; -x", `1:31: negation of -9223372036854775808 overflows`, evaluator.ErrorKindRange, nil},
+		{"x = -9223372036854775807 - 1; x * -1", `1:31: -9223372036854775808 * -1 overflows`, evaluator.ErrorKindRange, nil},
+		{"x = -9223372036854775807 - 1; x \\ -1", `1:31: -9223372036854775808 \ -1 overflows`, evaluator.ErrorKindRange, nil},
+		{"pow(true, 1)", `1:1: expected a number, got bool`, evaluator.ErrorKindType, []string{"pow"}},
+		{`"a" < 1`, `1:7: expected a string, got int`, evaluator.ErrorKindType, nil},
+		{`"a" * 2`, `1:1: expected a number, got string`, evaluator.ErrorKindType, nil},
+		{"1 % 0", `1:5: division by zero`, evaluator.ErrorKindRange, nil},
+		{"1 \\ (1 - 1)", `1:6: division by zero`, evaluator.ErrorKindRange, nil},
+		{"1 / 0", `1:5: division by zero`, evaluator.ErrorKindRange, nil},
+		{"0.0 / 0", `1:7: division by zero`, evaluator.ErrorKindRange, nil},
+		{"x = 1.5; x /= 0.0", `1:15: division by zero`, evaluator.ErrorKindRange, nil},
+		{"5.5 % 2", `1:1: expected an int, got float`, evaluator.ErrorKindType, nil},
+		{"1 & true", `1:5: expected an int, got bool`, evaluator.ErrorKindType, nil},
+		{"1 << -1", `1:6: negative shift count -1`, evaluator.ErrorKindRange, nil},
+		{"[1, 2][2]", `1:8: index 2 out of range for list of length 2`, evaluator.ErrorKindRange, nil},
+		{"[1, 2][-1]", `1:8: index -1 out of range for list of length 2`, evaluator.ErrorKindRange, nil},
+		{"[1, 2][0.5]", `1:8: expected an int, got float`, evaluator.ErrorKindType, nil},
+		{`"abc"[0]`, `1:1: expected a list, got string`, evaluator.ErrorKindType, nil},
+		{"[1, 2][1:3]", `1:1: slice bounds [1:3] out of range for list of length 2`, evaluator.ErrorKindRange, nil},
+		{"[1, 2][2:1]", `1:1: slice bounds [2:1] out of range for list of length 2`, evaluator.ErrorKindRange, nil},
+		{"len(1)", `1:1: expected a list or a string, got int`, evaluator.ErrorKindType, []string{"len"}},
+		{"order = {qty: 2}; order.price", `1:19: undefined field "price"`, evaluator.ErrorKindUndefined, nil},
+		{"[1].qty", `1:1: expected a record, got list`, evaluator.ErrorKindType, nil},
+		{"(a, b) = (1, 2, 3)", `1:1: wrong number of values to unpack: expected 2, got 3`, evaluator.ErrorKindArity, nil},
+		{"(a, [b, c]) = (1, [2])", `1:5: wrong number of values to unpack: expected 2, got 1`, evaluator.ErrorKindArity, nil},
+		{"[a, b] = 1", `1:1: expected a list, got int`, evaluator.ErrorKindType, nil},
+		{"{a} = [1]", `1:1: expected a record, got list`, evaluator.ErrorKindType, nil},
+		{"{a, b} = {a: 1}", `1:1: undefined field "b"`, evaluator.ErrorKindUndefined, nil},
+		{"undefined_total += 1", `1:1: undefined name "undefined_total"`, evaluator.ErrorKindUndefined, nil},
+		{"sqrt(-1)", `1:1: square root of negative number -1`, evaluator.ErrorKindRange, []string{"sqrt"}},
+		{`abs("a")`, `1:1: expected a number, got string`, evaluator.ErrorKindType, []string{"abs"}},
+		{"round(1, 2, 3)", `1:1: wrong number of arguments: expected 1 to 2, got 3`, evaluator.ErrorKindArity, []string{"round"}},
+		{"round(1.5, 0.5)", `1:1: expected an int, got float`, evaluator.ErrorKindType, []string{"round"}},
+		{"round(9223372036854775807, -1)", `1:1: rounding 9223372036854775807 to -1 digits overflows`, evaluator.ErrorKindRange, []string{"round"}},
+		{"round(5000000000000000000, -19)", `1:1: rounding 5000000000000000000 to -19 digits overflows`, evaluator.ErrorKindRange, []string{"round"}},
+		{"x |> 1", `1:6: expected a function, got int`, evaluator.ErrorKindType, nil},
+		{"round(1.5, places: 1)", `1:1: unknown argument "places"`, evaluator.ErrorKindArity, []string{"round"}},
+		{"round(1.5, x: 1)", `1:1: duplicate argument "x"`, evaluator.ErrorKindArity, []string{"round"}},
+		{"round(digits: 1)", `1:1: missing argument "x"`, evaluator.ErrorKindArity, []string{"round"}},
+		{"f = (a, b = 1) => a; f()", `1:22: wrong number of arguments: expected 1 to 2, got 0`, evaluator.ErrorKindArity, []string{"f"}},
+		{"f = (a, b = 1) => a; f(b: 2)", `1:22: missing argument "a"`, evaluator.ErrorKindArity, []string{"f"}},
+		{"f = (a = g()) => a; f()", `1:10: undefined name "g"`, evaluator.ErrorKindUndefined, []string{"f"}},
+		{"{ t = 1 }; t", `1:12: undefined name "t"`, evaluator.ErrorKindUndefined, nil},
+		{"for i in 0..1.5 {}", `1:13: expected an int, got float`, evaluator.ErrorKindType, nil},
+		{"for i in 0..3 { j }", `1:17: undefined name "j"`, evaluator.ErrorKindUndefined, nil},
+		{"infixl 6 <+> = 1", `1:16: expected a function, got int`, evaluator.ErrorKindType, nil},
+		{"infixl 6 <+> = (a) => a; 1 <+> 2", `1:26: wrong number of arguments: expected 1, got 2`, evaluator.ErrorKindArity, []string{"<+>"}},
+		{"f = (n) => f(n + 1); f(0)", `1:12: maximum call depth of 1000 exceeded`, evaluator.ErrorKindStackOverflow, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			lexer := lexer.New(tc.in)
+			parser := parser.New(lexer)
+			eval := evaluator.New()
+
+			expr, err := parser.ParseExpression()
+			rq.NoError(err)
+
+			_, err = eval.Eval(expr)
+			rq.EqualError(err, tc.err)
+
+			var failure *evaluator.RuntimeError
+
+			rq.ErrorAs(err, &failure)
+			rq.Equal(tc.kind, failure.Kind)
+
+			if tc.stack != nil {
+				var names []string
+
+				for _, frame := range failure.Stack {
+					names = append(names, frame.Name)
+				}
+
+				rq.Equal(tc.stack, names)
+			}
+		})
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		"",
+		"a + b",
+		"  a\t+\r\n b  \n",
+		"// header\nx = 1 // one\n\n/* two\n lines */ y = 2; /* trailing */\n",
+		"a /* unterminated",
+		"a $ b // illegal",
+	}
+
+	for _, in := range tt {
+		t.Run(in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			lex := lexer.New(in)
+
+			var sb strings.Builder
+
+			for {
+				token := lex.Next()
+
+				for _, trivia := range token.Leading {
+					sb.WriteString(trivia.Text)
+				}
+
+				sb.WriteString(token.Text)
+
+				for _, trivia := range token.Trailing {
+					sb.WriteString(trivia.Text)
+				}
+
+				if token.Type == lexer.TypeEOF {
+					break
+				}
+			}
+
+			rq.Equal(in, sb.String())
+		})
+	}
+}
+
+func TestTriviaAttachment(t *testing.T) {
+	t.Parallel()
+
+	rq := require.New(t)
+
+	lex := lexer.New("// header\nx = 1 // one\n/* two */ y")
+
+	x := lex.Next()
+	rq.Equal("x", x.Text)
+	rq.Equal([]string{"// header", "\n"}, triviaTexts(x.Leading))
+	rq.Equal([]string{" "}, triviaTexts(x.Trailing))
+
+	lex.Next()
+
+	one := lex.Next()
+	rq.Equal("1", one.Text)
+	rq.Equal([]string{" ", "// one", "\n"}, triviaTexts(one.Trailing))
+
+	y := lex.Next()
+	rq.Equal("y", y.Text)
+	rq.Equal([]string{"/* two */", " "}, triviaTexts(y.Leading))
+	rq.Equal(lexer.TriviaBlockComment, y.Leading[0].Kind)
+}
+
+func TestCommentRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out string
+	}{
+		{"// header\nx = 1 // one\n\n/* two */ y = a + /* c */ b; // trailing\n",
+			"// header\n(x = 1); // one\n/* two */ (y = (a + /* c */ b)) // trailing\n"},
+		{"a /* c */ * b", "(a /* c */ * b)"},
+		{"f(/* first */ a, b /* last */)", "f(/* first */ a, b /* last */)"},
+		{"f /* c */ |> f", "f(f /* c */)"},
+		{"(a + b /* c */) * [1 // one\n]", "((a + b /* c */) * [1 // one\n])"},
+		{"x = {a: 1 /* c */}; f(/* c */)", "(x = {a: 1 /* c */}); f() /* c */"},
+		{"if a { // then\n b } // if\nelse {}", "if a { // then\nb } // if\n else {}"},
+		{"x = { /* empty */ }", "(x = {} /* empty */)"},
+		{"{ a\n// end\n}", "{ a // end\n }"},
+		{"(a, /* b */ b) = t", "((a, /* b */ b) = t)"},
+		{"{a: /* x */ x} = r", "({a: /* x */ x} = r)"},
+		{"x |> /* f */ f(_, 1)", "/* f */ f(x, 1)"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			print := func(in string) string {
+				expr, err := parser.New(lexer.New(in)).ParseExpression()
+				rq.NoError(err)
+
+				pprint := printer.Printer()
+				expr.Visit(pprint)
+
+				return pprint.String()
+			}
+
+			out := print(tc.in)
+			rq.Equal(tc.out, out)
+			rq.Equal(out, print(out))
+		})
+	}
+}
+
+func triviaTexts(trivia []lexer.Trivia) []string {
+	var result []string
+
+	for _, t := range trivia {
+		result = append(result, t.Text)
+	}
+
+	return result
+}
+
+func TestStreaming(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		"",
+		"a + b",
+		"(x, y) => x <= y && \"héllo\\u{1F600}\" != 1_000.5e-3",
+		"// header\nx = 1 // one\n\n/* two\n lines */ y = 2; /* trailing */\n",
+		"a × b ≤ c /* unterminated",
+		"2π; a € b",
+		strings.Repeat("aa + ", 2000) + "/* " + strings.Repeat("*", 5000) + " */ b",
+	}
+
+	for _, in := range tt {
+		t.Run(in[:min(len(in), 40)], func(t *testing.T) {
+			t.Parallel()
+
+			rq := require.New(t)
+
+			// Reading one byte at a time splits multi-byte characters,
+			// punctuators and comments across reads.
+			expected := lexer.New(in)
+			actual := lexer.NewReader(iotest.OneByteReader(strings.NewReader(in)))
+
+			for {
+				token := expected.Next()
+
+				rq.Equal(token, actual.Next())
+
+				if token.Type == lexer.TypeEOF {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestStreamingReadError(t *testing.T) {
+	t.Parallel()
+
+	rq := require.New(t)
+
+	failure := errors.New("read failed")
+	reader := io.MultiReader(strings.NewReader("a + b"), iotest.ErrReader(failure))
+
+	_, err := parser.New(lexer.NewReader(reader)).ParseExpression()
+	rq.EqualError(err, "1:6: error: read failed")
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/corani/bantamgo/lexer"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic describes a problem found while parsing. For unexpected tokens,
+// Expected lists the token types that would have been accepted (if known) and
+// Found is the offending token.
+type Diagnostic struct {
+	Severity Severity
+	Span     lexer.Span
+	Message  string
+	Expected []lexer.TokenType
+	Found    lexer.Token
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%v: %v: %s", d.Span.Start, d.Severity, d.Message)
+}
+
+// Diagnostics is the list of problems found while parsing, in source order.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	messages := make([]string, 0, len(d))
+
+	for _, diag := range d {
+		messages = append(messages, diag.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// describe returns a human readable description of a token for use in
+// diagnostics.
+func describe(t lexer.Token) string {
+	if t.Type == lexer.TypeEOF {
+		return "end of input"
+	}
+
+	return fmt.Sprintf("%q", t.Text)
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"maps"
+
+	"github.com/corani/bantamgo/lexer"
+)
+
+// Grammar is the table of parselets a Parser uses, keyed by the token type
+// that starts them. Register operators on a grammar before creating a parser
+// with NewWithGrammar.
+type Grammar struct {
+	prefixParselets map[lexer.TokenType]PrefixParselet
+	infixParselets  map[lexer.TokenType]InfixParselet
+}
+
+// NewGrammar returns an empty grammar.
+func NewGrammar() *Grammar {
+	return &Grammar{
+		prefixParselets: make(map[lexer.TokenType]PrefixParselet),
+		infixParselets:  make(map[lexer.TokenType]InfixParselet),
+	}
+}
+
+// DefaultGrammar returns a new grammar with the Bantam operators, which can be
+// extended with more.
+func DefaultGrammar() *Grammar {
+	result := NewGrammar()
+
+	// Register special parselets
+	result.RegisterPrefix(lexer.TypeName, NameParselet())
+	result.RegisterPrefix(lexer.TypeNumber, NumberParselet())
+	result.RegisterPrefix(lexer.TypeString, StringParselet())
+	result.RegisterPrefix(lexer.TypeLParen, GroupParselet())
+	result.RegisterPrefix(lexer.TypeLBrace, BlockParselet())
+	result.RegisterPrefix(lexer.TypeLBracket, ListParselet())
+	result.RegisterInfix(lexer.TypeAssign, AssignParselet())
+	result.RegisterInfix(lexer.TypePlusAssign, CompoundAssignParselet(lexer.TypePlus))
+	result.RegisterInfix(lexer.TypeMinusAssign, CompoundAssignParselet(lexer.TypeMinus))
+	result.RegisterInfix(lexer.TypeAsteriskAssign, CompoundAssignParselet(lexer.TypeAsterisk))
+	result.RegisterInfix(lexer.TypeSlashAssign, CompoundAssignParselet(lexer.TypeSlash))
+	result.RegisterInfix(lexer.TypeCaretAssign, CompoundAssignParselet(lexer.TypeCaret))
+	result.RegisterInfix(lexer.TypeQuestion, ConditionalParselet())
+	result.RegisterInfix(lexer.TypeLParen, CallParselet())
+	result.RegisterInfix(lexer.TypeLBracket, IndexParselet())
+	result.RegisterInfix(lexer.TypeDot, MemberParselet())
+	result.RegisterInfix(lexer.TypePipeline, PipelineParselet())
+	result.RegisterPrefix(lexer.TypeUnderscore, PlaceholderParselet())
+
+	// Register control flow
+	result.RegisterPrefix(lexer.TypeIf, IfParselet())
+	result.RegisterPrefix(lexer.TypeWhile, WhileParselet())
+	result.RegisterPrefix(lexer.TypeFor, ForParselet())
+	result.RegisterPrefix(lexer.TypeBreak, BreakParselet())
+	result.RegisterPrefix(lexer.TypeContinue, ContinueParselet())
+
+	// Register operator declarations
+	result.RegisterPrefix(lexer.TypeInfixl, OperatorParselet(AssocLeft))
+	result.RegisterPrefix(lexer.TypeInfixr, OperatorParselet(AssocRight))
+
+	// Register simple prefix operators
+	result.RegisterPrefix(lexer.TypePlus, PrefixOperatorParselet(PrecPrefix))
+	result.RegisterPrefix(lexer.TypeMinus, PrefixOperatorParselet(PrecPrefix))
+	result.RegisterPrefix(lexer.TypeTilde, PrefixOperatorParselet(PrecPrefix))
+	result.RegisterPrefix(lexer.TypeBang, PrefixOperatorParselet(PrecPrefix))
+
+	// Register postfix factorial operator
+	result.RegisterPostfix(lexer.TypeBang, PostfixOperatorParselet(PrecPostfix))
+
+	// Register short-circuiting logical operators
+	result.RegisterInfix(lexer.TypeLogicalOr, LogicalOperatorParselet(PrecLogicalOr))
+	result.RegisterInfix(lexer.TypeLogicalAnd, LogicalOperatorParselet(PrecLogicalAnd))
+
+	// Register left-associative infix operators. As in Go, the bitwise
+	// operators bind like the arithmetic ones: "&", "<<" and ">>" like "*",
+	// "|" and "~" (xor) like "+".
+	result.RegisterInfix(lexer.TypeEqual, InfixOperatorParselet(PrecComparison, AssocLeft))
+	result.RegisterInfix(lexer.TypeNotEqual, InfixOperatorParselet(PrecComparison, AssocLeft))
+	result.RegisterInfix(lexer.TypeLess, InfixOperatorParselet(PrecComparison, AssocLeft))
+	result.RegisterInfix(lexer.TypeLessEqual, InfixOperatorParselet(PrecComparison, AssocLeft))
+	result.RegisterInfix(lexer.TypeGreater, InfixOperatorParselet(PrecComparison, AssocLeft))
+	result.RegisterInfix(lexer.TypeGreaterEqual, InfixOperatorParselet(PrecComparison, AssocLeft))
+	result.RegisterInfix(lexer.TypePlus, InfixOperatorParselet(PrecSum, AssocLeft))
+	result.RegisterInfix(lexer.TypeMinus, InfixOperatorParselet(PrecSum, AssocLeft))
+	result.RegisterInfix(lexer.TypePipe, InfixOperatorParselet(PrecSum, AssocLeft))
+	result.RegisterInfix(lexer.TypeTilde, InfixOperatorParselet(PrecSum, AssocLeft))
+	result.RegisterInfix(lexer.TypeAsterisk, InfixOperatorParselet(PrecProduct, AssocLeft))
+	result.RegisterInfix(lexer.TypeSlash, InfixOperatorParselet(PrecProduct, AssocLeft))
+	result.RegisterInfix(lexer.TypePercent, InfixOperatorParselet(PrecProduct, AssocLeft))
+	result.RegisterInfix(lexer.TypeBackslash, InfixOperatorParselet(PrecProduct, AssocLeft))
+	result.RegisterInfix(lexer.TypeAmpersand, InfixOperatorParselet(PrecProduct, AssocLeft))
+	result.RegisterInfix(lexer.TypeShiftLeft, InfixOperatorParselet(PrecProduct, AssocLeft))
+	result.RegisterInfix(lexer.TypeShiftRight, InfixOperatorParselet(PrecProduct, AssocLeft))
+
+	// Register right-associative infix operators
+	result.RegisterInfix(lexer.TypeCaret, InfixOperatorParselet(PrecExponent, AssocRight))
+
+	return result
+}
+
+// Clone returns a copy of the grammar that can be changed independently.
+func (g *Grammar) Clone() *Grammar {
+	return &Grammar{
+		prefixParselets: maps.Clone(g.prefixParselets),
+		infixParselets:  maps.Clone(g.infixParselets),
+	}
+}
+
+func (g *Grammar) RegisterPrefix(tt lexer.TokenType, parselet PrefixParselet) {
+	g.prefixParselets[tt] = parselet
+}
+
+func (g *Grammar) RegisterPostfix(tt lexer.TokenType, parselet InfixParselet) {
+	g.infixParselets[tt] = parselet
+}
+
+func (g *Grammar) RegisterInfix(tt lexer.TokenType, parselet InfixParselet) {
+	g.infixParselets[tt] = parselet
+}
+
+// Prefix registers a prefix operator spelled text, like "-" in "-a".
+func (g *Grammar) Prefix(text string, prec Precedence) (lexer.TokenType, error) {
+	tt, err := lexer.Operator(text)
+	if err == nil {
+		g.RegisterPrefix(tt, PrefixOperatorParselet(prec))
+	}
+
+	return tt, err
+}
+
+// Postfix registers a postfix operator spelled text, like "!" in "a!".
+func (g *Grammar) Postfix(text string, prec Precedence) (lexer.TokenType, error) {
+	tt, err := lexer.Operator(text)
+	if err == nil {
+		g.RegisterPostfix(tt, PostfixOperatorParselet(prec))
+	}
+
+	return tt, err
+}
+
+// Infix registers a binary operator spelled text, like "+" in "a + b".
+func (g *Grammar) Infix(text string, prec Precedence, assoc Associativity) (lexer.TokenType, error) {
+	tt, err := lexer.Operator(text)
+	if err == nil {
+		g.RegisterInfix(tt, InfixOperatorParselet(prec, assoc))
+	}
+
+	return tt, err
+}
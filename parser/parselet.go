@@ -1,33 +1,46 @@
 package parser
 
 import (
-	"fmt"
+	"errors"
+	"slices"
+	"strconv"
 
 	"github.com/corani/bantamgo/ast"
 	"github.com/corani/bantamgo/lexer"
 )
 
+// PrefixParselet parses an expression that starts with the token t, which has
+// already been consumed.
 type PrefixParselet interface {
-	Parse(p *parser, t lexer.Token) (ast.Expression, error)
+	Parse(p *Parser, t lexer.Token) (ast.Expression, error)
 }
 
-type prefixParseletFunc func(p *parser, t lexer.Token) (ast.Expression, error)
+// PrefixParseletFunc adapts a function to the PrefixParselet interface.
+type PrefixParseletFunc func(p *Parser, t lexer.Token) (ast.Expression, error)
 
-func (p prefixParseletFunc) Parse(parser *parser, t lexer.Token) (ast.Expression, error) {
+func (p PrefixParseletFunc) Parse(parser *Parser, t lexer.Token) (ast.Expression, error) {
 	return p(parser, t)
 }
 
+// InfixParselet parses the rest of an expression when the token t follows the
+// already parsed left operand. Precedence decides how tightly it binds.
 type InfixParselet interface {
-	Parse(p *parser, left ast.Expression, t lexer.Token) (ast.Expression, error)
+	Parse(p *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error)
 	Precedence() Precedence
 }
 
+// NewInfixParselet returns an InfixParselet with the given precedence that
+// parses using the given function.
+func NewInfixParselet(prec Precedence, parse func(p *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error)) InfixParselet {
+	return &infixParselet{parse: parse, prec: prec}
+}
+
 type infixParselet struct {
-	parse func(p *parser, left ast.Expression, t lexer.Token) (ast.Expression, error)
+	parse func(p *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error)
 	prec  Precedence
 }
 
-func (i *infixParselet) Parse(parser *parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+func (i *infixParselet) Parse(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
 	return i.parse(parser, left, t)
 }
 
@@ -38,34 +51,105 @@ func (i *infixParselet) Precedence() Precedence {
 // ----- NAME PARSELET -----
 
 func NameParselet() PrefixParselet {
-	return prefixParseletFunc(func(parser *parser, t lexer.Token) (ast.Expression, error) {
-		return ast.NameExpression(t.Text), nil
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		return ast.NameExpression(t.Span, t.Text), nil
 	})
 }
 
 // ----- NUMBER PARSELET -----
 
 func NumberParselet() PrefixParselet {
-	return prefixParseletFunc(func(parser *parser, t lexer.Token) (ast.Expression, error) {
-		return ast.NumberExpression(t.Text)
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		var (
+			expr ast.Expression
+			err  error
+		)
+
+		// Numbers without a fraction or exponent are integers.
+		if lexer.IsFloat(t.Text) {
+			expr, err = ast.NumberExpression(t.Span, t.Text)
+		} else {
+			expr, err = ast.IntegerExpression(t.Span, t.Text)
+		}
+
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, parser.Errorf(t.Span, "number %q out of range", t.Text)
+		} else if err != nil {
+			return nil, parser.Errorf(t.Span, "invalid number %q", t.Text)
+		}
+
+		return expr, nil
+	})
+}
+
+// ----- STRING PARSELET -----
+
+func StringParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		expr, err := ast.StringExpression(t.Span, t.Text)
+		if err != nil {
+			return nil, parser.Errorf(t.Span, "%v", err)
+		}
+
+		return expr, nil
 	})
 }
 
 // ----- ASSIGN PARSELET -----
 
+// AssignParselet parses an assignment to a name, or a destructuring assignment
+// like "(a, b) = (b, a)" if the left-hand side is a tuple, list or record.
 func AssignParselet() InfixParselet {
 	return &infixParselet{
-		parse: func(parser *parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
-			right, err := parser.parseExpression(PrecAssignment - 1)
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			right, err := parser.Parse(PrecAssignment - 1)
+			if err != nil {
+				return nil, err
+			}
+
+			span := left.Span().To(right.Span())
+
+			switch left := left.(type) {
+			case *ast.NameExpressionNode:
+				result := ast.AssignExpression(span, left.Name, right)
+				*result.Comments() = *left.Comments()
+
+				return result, nil
+			case *ast.TupleExpressionNode, *ast.ListExpressionNode, *ast.RecordExpressionNode:
+				pattern, err := parser.pattern(left, make(map[string]bool))
+				if err != nil {
+					return nil, err
+				}
+
+				return ast.DestructureExpression(span, pattern, right), nil
+			default:
+				return nil, parser.Errorf(left.Span(), "the left-hand side of an assignment must be a name or a pattern")
+			}
+		},
+		prec: PrecAssignment,
+	}
+}
+
+// ----- COMPOUND ASSIGN PARSELET -----
+
+// CompoundAssignParselet parses an assignment like "a += b", which is short for
+// "a = a + b" with the given operator.
+func CompoundAssignParselet(operator lexer.TokenType) InfixParselet {
+	return &infixParselet{
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			right, err := parser.Parse(PrecAssignment - 1)
 			if err != nil {
 				return nil, err
 			}
 
-			if name, ok := left.(*ast.NameExpressionNode); ok {
-				return ast.AssignExpression(name.Name, right), nil
+			name, ok := left.(*ast.NameExpressionNode)
+			if !ok {
+				return nil, parser.Errorf(left.Span(), "the left-hand side of %q must be a name", t.Text)
 			}
 
-			return nil, fmt.Errorf("the left-hand of an assignment must be a name")
+			span := left.Span().To(right.Span())
+
+			return ast.AssignExpression(span, name.Name, ast.InfixExpression(span, left, operator, right)), nil
 		},
 		prec: PrecAssignment,
 	}
@@ -75,20 +159,22 @@ func AssignParselet() InfixParselet {
 
 func ConditionalParselet() InfixParselet {
 	return &infixParselet{
-		parse: func(parser *parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
-			thenBranch, err := parser.parseExpression(0)
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			thenBranch, err := parser.Parse(0)
 			if err != nil {
 				return nil, err
 			}
 
-			parser.expect(lexer.TypeColon)
+			if _, err := parser.Expect(lexer.TypeColon); err != nil {
+				return nil, err
+			}
 
-			elseBranch, err := parser.parseExpression(PrecConditional - 1)
+			elseBranch, err := parser.Parse(PrecConditional - 1)
 			if err != nil {
 				return nil, err
 			}
 
-			return ast.ConditionalExpression(left, thenBranch, elseBranch), nil
+			return ast.ConditionalExpression(left.Span().To(elseBranch.Span()), left, thenBranch, elseBranch), nil
 		},
 		prec: PrecConditional,
 	}
@@ -97,15 +183,413 @@ func ConditionalParselet() InfixParselet {
 // ----- GROUP PARSELET -----
 
 func GroupParselet() PrefixParselet {
-	return prefixParseletFunc(func(parser *parser, t lexer.Token) (ast.Expression, error) {
-		expr, err := parser.parseExpression(0)
+	lambda := LambdaParselet()
+
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		// A parenthesized list followed by "=>" is a parameter list.
+		if parser.isLambda() {
+			return lambda.Parse(parser, t)
+		}
+
+		expr, err := parser.Parse(0)
 		if err != nil {
 			return nil, err
 		}
 
-		parser.expect(lexer.TypeRParen)
+		if !parser.Match(lexer.TypeComma) {
+			if _, err := parser.Expect(lexer.TypeRParen); err != nil {
+				return nil, err
+			}
 
-		return expr, nil
+			return expr, nil
+		}
+
+		// A comma makes it a tuple.
+		elements := []ast.Expression{expr}
+
+		for {
+			element, err := parser.Parse(0)
+			if err != nil {
+				return nil, err
+			}
+
+			elements = append(elements, element)
+
+			if !parser.Match(lexer.TypeComma) {
+				break
+			}
+		}
+
+		end, err := parser.Expect(lexer.TypeRParen)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.TupleExpression(t.Span.To(end.Span), elements), nil
+	})
+}
+
+// ----- LAMBDA PARSELET -----
+
+func LambdaParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		// The parameters and body of a function aren't inside the loops
+		// around it.
+		loops := parser.loops
+		parser.loops = 0
+
+		defer func() { parser.loops = loops }()
+
+		var params []ast.Parameter
+
+		if parser.LookAhead(0).Type != lexer.TypeRParen {
+			for {
+				param, err := parser.Expect(lexer.TypeName)
+				if err != nil {
+					return nil, err
+				}
+
+				if slices.ContainsFunc(params, func(p ast.Parameter) bool { return p.Name == param.Text }) {
+					return nil, parser.Errorf(param.Span, "duplicate parameter %q", param.Text)
+				}
+
+				// Parameters with a default value come after the ones without,
+				// so positional arguments fill the required ones first.
+				var value ast.Expression
+
+				if parser.Match(lexer.TypeAssign) {
+					if value, err = parser.Parse(PrecAssignment); err != nil {
+						return nil, err
+					}
+				} else if len(params) > 0 && params[len(params)-1].Default != nil {
+					return nil, parser.Errorf(param.Span, "parameter %q without a default follows a parameter with one", param.Text)
+				}
+
+				params = append(params, ast.Parameter{Name: param.Text, Default: value})
+
+				if !parser.Match(lexer.TypeComma) {
+					break
+				}
+			}
+		}
+
+		if _, err := parser.Expect(lexer.TypeRParen); err != nil {
+			return nil, err
+		}
+
+		if _, err := parser.Expect(lexer.TypeArrow); err != nil {
+			return nil, err
+		}
+
+		body, err := parser.Parse(0)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.LambdaExpression(t.Span.To(body.Span()), params, body), nil
+	})
+}
+
+// ----- OPERATOR PARSELET -----
+
+// OperatorParselet parses an operator declaration like "infixl 6 <+> = f". The
+// operator is added to the parser's table right away, so it can be used in the
+// rest of the input, including the function that implements it. The operator
+// is spelled by the adjacent symbols following the precedence, so it must be
+// separated from the "=" by whitespace.
+//
+// Declarations must be top-level statements, so the operator is defined at run
+// time wherever the parser accepts it.
+func OperatorParselet(assoc Associativity) PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		nested := parser.depth > 1
+
+		number, err := parser.Expect(lexer.TypeNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		prec, err := strconv.Atoi(number.Text)
+		if err != nil || prec < 1 || prec > len(scriptPrecedences) {
+			return nil, parser.Errorf(number.Span, "invalid precedence %q, expected an integer between %d and %d",
+				number.Text, 1, len(scriptPrecedences))
+		}
+
+		text, span, err := parser.operator()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := parser.checkOperator(text, span); err != nil {
+			return nil, err
+		}
+
+		if _, err := parser.Expect(lexer.TypeAssign); err != nil {
+			return nil, err
+		}
+
+		parser.operators[text] = scriptOperatorParselet(scriptPrecedences[prec-1], assoc)
+		parser.tokens.DefinePunctuator(text, lexer.TypeOperator)
+
+		function, err := parser.Parse(PrecAssignment - 1)
+		if err != nil {
+			return nil, err
+		}
+
+		// The declaration is parsed first, so the rest of the input is parsed
+		// as if it were allowed.
+		if nested {
+			return nil, parser.Errorf(t.Span, "operator declarations are only allowed at the top level")
+		}
+
+		return ast.OperatorExpression(t.Span.To(function.Span()), text, prec, assoc == AssocRight, function), nil
+	})
+}
+
+// checkOperator reports whether a script may declare the operator spelled
+// text. Besides the spellings that are reserved or already defined, this
+// rejects those that would change how valid code is split into tokens, like
+// "<-" in "a <-b", which is "a < (-b)" without it.
+func (p *Parser) checkOperator(text string, span lexer.Span) error {
+	if !lexer.IsOperator(text) {
+		return p.Errorf(span, "invalid operator %q", text)
+	}
+
+	if lexer.IsReserved(text) {
+		return p.Errorf(span, "operator %q is reserved", text)
+	}
+
+	tokens, ok := p.tokens.Split(text)
+	if !ok || len(tokens) == 0 {
+		return nil
+	}
+
+	if len(tokens) == 1 {
+		return p.Errorf(span, "operator %q is already defined", text)
+	}
+
+	if _, ok := p.infix(tokens[0]); !ok {
+		return nil
+	}
+
+	for _, t := range tokens[1:] {
+		if _, ok := p.prefixParselets[t.Type]; !ok {
+			return nil
+		}
+	}
+
+	return p.Errorf(span, "operator %q would change the meaning of \"a %s %sb\"",
+		text, tokens[0].Text, text[len(tokens[0].Text):])
+}
+
+// ----- BLOCK PARSELET -----
+
+// BlockParselet parses a nested block like "{ t = a * 2; t + 1 }", which
+// evaluates to its last expression. Braces starting with a name followed by a
+// ':' or ',' are a record like "{qty: 2, price}" instead, as is a single name
+// in braces that's assigned to, like "{qty} = order". "{}" is an empty block,
+// the empty record is spelled "{:}".
+func BlockParselet() PrefixParselet {
+	record := RecordParselet()
+
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		if parser.LookAhead(0).Type == lexer.TypeColon {
+			return record.Parse(parser, t)
+		}
+
+		if parser.LookAhead(0).Type == lexer.TypeName {
+			switch parser.LookAhead(1).Type {
+			case lexer.TypeColon, lexer.TypeComma:
+				return record.Parse(parser, t)
+			case lexer.TypeRBrace:
+				if parser.LookAhead(2).Type == lexer.TypeAssign {
+					return record.Parse(parser, t)
+				}
+			}
+		}
+
+		return parser.finishBraces(t)
+	})
+}
+
+// ----- RECORD PARSELET -----
+
+// RecordParselet parses a record literal. A field without a value, like "qty"
+// in "{qty, price: 1.5}", takes the value of the name. The empty record is
+// "{:}".
+func RecordParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		var fields []ast.Field
+
+		if parser.Match(lexer.TypeColon) {
+			end, err := parser.Expect(lexer.TypeRBrace)
+			if err != nil {
+				return nil, err
+			}
+
+			return ast.RecordExpression(t.Span.To(end.Span), fields), nil
+		}
+
+		for {
+			name, err := parser.Expect(lexer.TypeName)
+			if err != nil {
+				return nil, err
+			}
+
+			if slices.ContainsFunc(fields, func(f ast.Field) bool { return f.Name == name.Text }) {
+				return nil, parser.Errorf(name.Span, "duplicate field %q", name.Text)
+			}
+
+			var value ast.Expression = ast.NameExpression(name.Span, name.Text)
+
+			if parser.Match(lexer.TypeColon) {
+				if value, err = parser.Parse(0); err != nil {
+					return nil, err
+				}
+			}
+
+			fields = append(fields, ast.Field{Name: name.Text, Value: value})
+
+			if !parser.Match(lexer.TypeComma) {
+				break
+			}
+		}
+
+		end, err := parser.Expect(lexer.TypeRBrace)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.RecordExpression(t.Span.To(end.Span), fields), nil
+	})
+}
+
+// ----- MEMBER PARSELET -----
+
+func MemberParselet() InfixParselet {
+	return &infixParselet{
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			name, err := parser.Expect(lexer.TypeName)
+			if err != nil {
+				return nil, err
+			}
+
+			return ast.MemberExpression(left.Span().To(name.Span), left, name.Text), nil
+		},
+		prec: PrecCall,
+	}
+}
+
+// ----- IF PARSELET -----
+
+func IfParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		condition, err := parser.Parse(0)
+		if err != nil {
+			return nil, err
+		}
+
+		thenBranch, err := parser.parseBraces()
+		if err != nil {
+			return nil, err
+		}
+
+		if !parser.Match(lexer.TypeElse) {
+			return ast.IfExpression(t.Span.To(thenBranch.Span()), condition, thenBranch, nil), nil
+		}
+
+		// "else if" chains without nesting the braces.
+		var elseBranch ast.Expression
+
+		if parser.LookAhead(0).Type == lexer.TypeIf {
+			elseBranch, err = parser.Parse(0)
+		} else {
+			elseBranch, err = parser.parseBraces()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.IfExpression(t.Span.To(elseBranch.Span()), condition, thenBranch, elseBranch), nil
+	})
+}
+
+// ----- WHILE PARSELET -----
+
+func WhileParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		condition, err := parser.Parse(0)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := parser.parseLoopBody()
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.WhileExpression(t.Span.To(body.Span()), condition, body), nil
+	})
+}
+
+// ----- FOR PARSELET -----
+
+// ForParselet parses "for i in a..b { ... }". The range is only valid in a for
+// loop, so ".." isn't an operator.
+func ForParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		name, err := parser.Expect(lexer.TypeName)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := parser.Expect(lexer.TypeIn); err != nil {
+			return nil, err
+		}
+
+		start, err := parser.Parse(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := parser.Expect(lexer.TypeDotDot); err != nil {
+			return nil, err
+		}
+
+		end, err := parser.Parse(0)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := parser.parseLoopBody()
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.ForExpression(t.Span.To(body.Span()), name.Text, start, end, body), nil
+	})
+}
+
+// ----- BREAK AND CONTINUE PARSELETS -----
+
+func BreakParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		if parser.loops == 0 {
+			return nil, parser.Errorf(t.Span, "break outside of a loop")
+		}
+
+		return ast.BreakExpression(t.Span), nil
+	})
+}
+
+func ContinueParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		if parser.loops == 0 {
+			return nil, parser.Errorf(t.Span, "continue outside of a loop")
+		}
+
+		return ast.ContinueExpression(t.Span), nil
 	})
 }
 
@@ -113,42 +597,250 @@ func GroupParselet() PrefixParselet {
 
 func CallParselet() InfixParselet {
 	return &infixParselet{
-		parse: func(parser *parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
-			var args []ast.Expression
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			var (
+				args       []ast.Expression
+				named      []ast.NamedArgument
+				underscore *lexer.Token
+			)
+
+			// argument parses an argument, which is nil if it's the
+			// placeholder of a pipeline.
+			argument := func() (ast.Expression, error) {
+				next := parser.LookAhead(0)
+
+				if next.Type != lexer.TypeUnderscore || parser.pipelines == 0 {
+					return parser.Parse(0)
+				}
+
+				if after := parser.LookAhead(1).Type; after != lexer.TypeComma && after != lexer.TypeRParen {
+					return parser.Parse(0)
+				}
 
-			if !parser.match(lexer.TypeRParen) {
+				if underscore != nil {
+					return nil, parser.Errorf(next.Span, "only one placeholder is allowed in a pipeline")
+				}
+
+				t := parser.Consume()
+				underscore = &t
+
+				return nil, nil
+			}
+
+			if parser.LookAhead(0).Type != lexer.TypeRParen {
 				for {
-					arg, err := parser.parseExpression(0)
-					if err != nil {
-						return nil, err
+					// A name followed by a colon is a named argument. Named
+					// arguments come after the positional ones.
+					if parser.LookAhead(0).Type == lexer.TypeName && parser.LookAhead(1).Type == lexer.TypeColon {
+						name := parser.Consume()
+						parser.Consume()
+
+						if slices.ContainsFunc(named, func(arg ast.NamedArgument) bool { return arg.Name == name.Text }) {
+							return nil, parser.Errorf(name.Span, "duplicate argument %q", name.Text)
+						}
+
+						value, err := argument()
+						if err != nil {
+							return nil, err
+						}
+
+						named = append(named, ast.NamedArgument{Name: name.Text, Value: value})
+					} else {
+						start := parser.LookAhead(0).Span
+
+						arg, err := argument()
+						if err != nil {
+							return nil, err
+						}
+
+						if len(named) > 0 {
+							return nil, parser.Errorf(start, "positional argument after a named argument")
+						}
+
+						args = append(args, arg)
 					}
 
-					args = append(args, arg)
-
-					if !parser.match(lexer.TypeComma) {
+					if !parser.Match(lexer.TypeComma) {
 						break
 					}
 				}
+			}
+
+			end, err := parser.Expect(lexer.TypeRParen)
+			if err != nil {
+				return nil, err
+			}
+
+			call := ast.CallExpression(left.Span().To(end.Span), left, args, named)
 
-				parser.expect(lexer.TypeRParen)
+			if underscore != nil {
+				parser.placeholders = append(parser.placeholders, placeholder{call: call, span: underscore.Span})
 			}
 
-			return ast.CallExpression(left, args), nil
+			return call, nil
 		},
 		prec: PrecCall,
 	}
 }
 
+// ----- LIST PARSELET -----
+
+func ListParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		var elements []ast.Expression
+
+		if parser.LookAhead(0).Type != lexer.TypeRBracket {
+			for {
+				element, err := parser.Parse(0)
+				if err != nil {
+					return nil, err
+				}
+
+				elements = append(elements, element)
+
+				if !parser.Match(lexer.TypeComma) {
+					break
+				}
+			}
+		}
+
+		end, err := parser.Expect(lexer.TypeRBracket)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.ListExpression(t.Span.To(end.Span), elements), nil
+	})
+}
+
+// ----- INDEX PARSELET -----
+
+// IndexParselet parses "xs[i]" and the slices "xs[a:b]", "xs[a:]" and "xs[:b]".
+func IndexParselet() InfixParselet {
+	return &infixParselet{
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			var start, end ast.Expression
+
+			if parser.LookAhead(0).Type != lexer.TypeColon {
+				index, err := parser.Parse(0)
+				if err != nil {
+					return nil, err
+				}
+
+				switch next := parser.LookAhead(0); next.Type {
+				case lexer.TypeRBracket:
+					last := parser.Consume()
+
+					return ast.IndexExpression(left.Span().To(last.Span), left, index), nil
+				case lexer.TypeColon:
+					start = index
+				default:
+					return nil, parser.unexpected(next, lexer.TypeRBracket, lexer.TypeColon)
+				}
+			}
+
+			parser.Consume()
+
+			if parser.LookAhead(0).Type != lexer.TypeRBracket {
+				var err error
+
+				if end, err = parser.Parse(0); err != nil {
+					return nil, err
+				}
+			}
+
+			last, err := parser.Expect(lexer.TypeRBracket)
+			if err != nil {
+				return nil, err
+			}
+
+			return ast.SliceExpression(left.Span().To(last.Span), left, start, end), nil
+		},
+		prec: PrecCall,
+	}
+}
+
+// ----- PIPELINE PARSELET -----
+
+// PipelineParselet parses "x |> f", which is short for "f(x)". If the right-hand
+// side is a call with "_" as one of its arguments, the left-hand side takes the
+// place of the "_" instead, so "x |> round(_, 2)" is "round(x, 2)". A "_"
+// anywhere else is an error.
+func PipelineParselet() InfixParselet {
+	return &infixParselet{
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			mark := len(parser.placeholders)
+
+			parser.pipelines++
+			right, err := parser.Parse(PrecPipeline)
+			parser.pipelines--
+
+			placeholders := slices.Clone(parser.placeholders[mark:])
+			parser.placeholders = parser.placeholders[:mark]
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, placeholder := range placeholders {
+				if placeholder.call != right {
+					return nil, placeholderError(parser, placeholder.span)
+				}
+			}
+
+			span := left.Span().To(right.Span())
+
+			if len(placeholders) == 0 {
+				return ast.CallExpression(span, right, []ast.Expression{left}, nil), nil
+			}
+
+			call := placeholders[0].call
+			args := slices.Clone(call.Args)
+			named := slices.Clone(call.Named)
+
+			for i := range args {
+				if args[i] == nil {
+					args[i] = left
+				}
+			}
+
+			for i := range named {
+				if named[i].Value == nil {
+					named[i].Value = left
+				}
+			}
+
+			result := ast.CallExpression(span, call.Callee, args, named)
+			*result.Comments() = *call.Comments()
+
+			return result, nil
+		},
+		prec: PrecPipeline,
+	}
+}
+
+// PlaceholderParselet rejects a "_" that isn't an argument of the call on the
+// right-hand side of a pipeline, CallParselet handles the ones that are.
+func PlaceholderParselet() PrefixParselet {
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		return nil, placeholderError(parser, t.Span)
+	})
+}
+
+func placeholderError(parser *Parser, span lexer.Span) error {
+	return parser.Errorf(span, "%q is only allowed as an argument of the call after %q", "_", "|>")
+}
+
 // ----- PREFIX OPERATOR PARSELET -----
 
 func PrefixOperatorParselet(prec Precedence) PrefixParselet {
-	return prefixParseletFunc(func(parser *parser, t lexer.Token) (ast.Expression, error) {
-		right, err := parser.parseExpression(prec)
+	return PrefixParseletFunc(func(parser *Parser, t lexer.Token) (ast.Expression, error) {
+		right, err := parser.Parse(prec)
 		if err != nil {
 			return nil, err
 		}
 
-		return ast.PrefixExpression(t.Type, right), nil
+		return ast.PrefixExpression(t.Span.To(right.Span()), t.Type, right), nil
 	})
 }
 
@@ -156,8 +848,48 @@ func PrefixOperatorParselet(prec Precedence) PrefixParselet {
 
 func PostfixOperatorParselet(prec Precedence) InfixParselet {
 	return &infixParselet{
-		parse: func(parser *parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
-			return ast.PostfixExpression(left, t.Type), nil
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			return ast.PostfixExpression(left.Span().To(t.Span), left, t.Type), nil
+		},
+		prec: prec,
+	}
+}
+
+// ----- LOGICAL OPERATOR PARSELET -----
+
+func LogicalOperatorParselet(prec Precedence) InfixParselet {
+	return &infixParselet{
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			right, err := parser.Parse(prec)
+			if err != nil {
+				return nil, err
+			}
+
+			return ast.LogicalExpression(left.Span().To(right.Span()), left, t.Type, right), nil
+		},
+		prec: prec,
+	}
+}
+
+// ----- SCRIPT OPERATOR PARSELET -----
+
+// scriptOperatorParselet parses an infix operator declared in the input, see
+// InfixOperatorParselet.
+func scriptOperatorParselet(prec Precedence, assoc Associativity) InfixParselet {
+	return &infixParselet{
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+			prec := prec
+
+			if assoc == AssocRight {
+				prec--
+			}
+
+			right, err := parser.Parse(prec)
+			if err != nil {
+				return nil, err
+			}
+
+			return ast.ScriptInfixExpression(left.Span().To(right.Span()), left, t.Text, right), nil
 		},
 		prec: prec,
 	}
@@ -167,7 +899,7 @@ func PostfixOperatorParselet(prec Precedence) InfixParselet {
 
 func InfixOperatorParselet(prec Precedence, assoc Associativity) InfixParselet {
 	return &infixParselet{
-		parse: func(parser *parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
+		parse: func(parser *Parser, left ast.Expression, t lexer.Token) (ast.Expression, error) {
 			// To handle right-associative operators like "^", we allow a slightly
 			// lower precedence when parsing the right-hand side. This will let a
 			// parselet with the same precedence appear on the right, which will then
@@ -178,12 +910,12 @@ func InfixOperatorParselet(prec Precedence, assoc Associativity) InfixParselet {
 				prec--
 			}
 
-			right, err := parser.parseExpression(prec)
+			right, err := parser.Parse(prec)
 			if err != nil {
 				return nil, err
 			}
 
-			return ast.InfixExpression(left, t.Type, right), nil
+			return ast.InfixExpression(left.Span().To(right.Span()), left, t.Type, right), nil
 		},
 		prec: prec,
 	}
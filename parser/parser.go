@@ -1,104 +1,225 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"strings"
 
 	"github.com/corani/bantamgo/ast"
 	"github.com/corani/bantamgo/lexer"
 )
 
-type parser struct {
+// Parser is a Pratt parser. The operators it understands are defined by its
+// Grammar.
+type Parser struct {
 	tokens          *lexer.Lexer
 	read            []lexer.Token
 	prefixParselets map[lexer.TokenType]PrefixParselet
 	infixParselets  map[lexer.TokenType]InfixParselet
+	// operators holds the infix operators declared in the input, which are
+	// lexed as TypeOperator, by their spelling.
+	operators   map[string]InfixParselet
+	diagnostics Diagnostics
+	// braces and loops count the enclosing braced blocks and loops.
+	braces int
+	loops  int
+	// depth counts the nested calls to Parse, it's 1 for a top-level
+	// statement.
+	depth int
+	// pipelines counts the pipelines whose right-hand side is being parsed,
+	// and placeholders holds the calls with a "_" argument parsed in them.
+	pipelines    int
+	placeholders []placeholder
+	// comments holds the comments of the consumed tokens that aren't attached
+	// to a node yet, and taken is the offset of the last token whose leading
+	// comments were taken before it was consumed.
+	comments []lexer.Trivia
+	taken    int
+	// last is the innermost expression parsed since the last token was
+	// consumed, the comments before a closing bracket following it belong to
+	// it.
+	last ast.Expression
 }
 
-func New(l *lexer.Lexer) *parser {
-	result := &parser{
+// placeholder is a call with a "_" argument, which is left nil until the
+// pipeline fills it in.
+type placeholder struct {
+	call *ast.CallExpressionNode
+	span lexer.Span
+}
+
+// New returns a parser for the default Bantam grammar.
+func New(l *lexer.Lexer) *Parser {
+	return NewWithGrammar(l, DefaultGrammar())
+}
+
+// NewWithGrammar returns a parser for the given grammar. The operators of the
+// grammar are defined in the lexer, so their spellings are recognized as tokens.
+// Changing the grammar afterwards doesn't affect the parser.
+func NewWithGrammar(l *lexer.Lexer, g *Grammar) *Parser {
+	result := &Parser{
 		tokens:          l,
 		read:            nil,
-		prefixParselets: make(map[lexer.TokenType]PrefixParselet),
-		infixParselets:  make(map[lexer.TokenType]InfixParselet),
+		prefixParselets: maps.Clone(g.prefixParselets),
+		infixParselets:  maps.Clone(g.infixParselets),
+		operators:       make(map[string]InfixParselet),
+		taken:           -1,
 	}
 
-	// Register special parselets
-	result.registerPrefix(lexer.TypeName, NameParselet())
-	result.registerPrefix(lexer.TypeNumber, NumberParselet())
-	result.registerPrefix(lexer.TypeLParen, GroupParselet())
-	result.registerInfix(lexer.TypeAssign, AssignParselet())
-	result.registerInfix(lexer.TypeQuestion, ConditionalParselet())
-	result.registerInfix(lexer.TypeLParen, CallParselet())
+	for tt := range result.prefixParselets {
+		result.definePunctuator(tt)
+	}
 
-	// Register simple prefix operators
-	result.registerPrefix(lexer.TypePlus, PrefixOperatorParselet(PrecPrefix))
-	result.registerPrefix(lexer.TypeMinus, PrefixOperatorParselet(PrecPrefix))
-	result.registerPrefix(lexer.TypeTilde, PrefixOperatorParselet(PrecPrefix))
-	result.registerPrefix(lexer.TypeBang, PrefixOperatorParselet(PrecPrefix))
+	for tt := range result.infixParselets {
+		result.definePunctuator(tt)
+	}
 
-	// Register postfix factorial operator
-	result.registerPostfix(lexer.TypeBang, PostfixOperatorParselet(PrecPostfix))
+	return result
+}
 
-	// Register left-associative infix operators
-	result.registerInfix(lexer.TypePlus, InfixOperatorParselet(PrecSum, AssocLeft))
-	result.registerInfix(lexer.TypeMinus, InfixOperatorParselet(PrecSum, AssocLeft))
-	result.registerInfix(lexer.TypeAsterisk, InfixOperatorParselet(PrecProduct, AssocLeft))
-	result.registerInfix(lexer.TypeSlash, InfixOperatorParselet(PrecProduct, AssocLeft))
+// definePunctuator tells the lexer about the spelling of an operator.
+func (p *Parser) definePunctuator(tt lexer.TokenType) {
+	if text, ok := tt.Punctuator(); ok {
+		p.tokens.DefinePunctuator(text, tt)
+	}
+}
 
-	// Register right-associative infix operators
-	result.registerInfix(lexer.TypeCaret, InfixOperatorParselet(PrecExponent, AssocRight))
+// ParseExpression parses the whole input as a block of statements. Parsing
+// doesn't stop at the first error: statements that fail to parse are left out
+// of the returned block, and all problems are returned as Diagnostics.
+func (p *Parser) ParseExpression() (ast.Expression, error) {
+	block := p.parseBlock()
 
-	return result
+	if len(p.diagnostics) > 0 {
+		return block, p.diagnostics
+	}
+
+	return block, nil
+}
+
+// Diagnostics returns the problems found so far.
+func (p *Parser) Diagnostics() Diagnostics {
+	return p.diagnostics
 }
 
-func (p *parser) registerPrefix(tt lexer.TokenType, parselet PrefixParselet) {
-	p.prefixParselets[tt] = parselet
+func (p *Parser) parseBlock() ast.Expression {
+	span := p.LookAhead(0).Span
+
+	statements, comments := p.parseStatements(lexer.TypeEOF)
+
+	if len(statements) > 0 {
+		span = statements[0].Span().To(statements[len(statements)-1].Span())
+	}
+
+	block := ast.BlockExpression(span, statements)
+	block.Comments().Trailing = comments
+
+	return block
 }
 
-func (p *parser) registerPostfix(tt lexer.TokenType, parselet InfixParselet) {
-	p.infixParselets[tt] = parselet
+// parseBraces parses a block of statements between braces.
+func (p *Parser) parseBraces() (ast.Expression, error) {
+	start, err := p.Expect(lexer.TypeLBrace)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finishBraces(start)
 }
 
-func (p *parser) registerInfix(tt lexer.TokenType, parselet InfixParselet) {
-	p.infixParselets[tt] = parselet
+// finishBraces parses the rest of a braced block, after the opening brace.
+func (p *Parser) finishBraces(start lexer.Token) (ast.Expression, error) {
+	p.braces++
+	statements, comments := p.parseStatements(lexer.TypeRBrace)
+	p.braces--
+
+	end, err := p.Expect(lexer.TypeRBrace)
+	if err != nil {
+		return nil, err
+	}
+
+	// The comments after the '}' on the same line belong to the block.
+	block := ast.BlockExpression(start.Span.To(end.Span), statements)
+	block.Comments().Trailing = append(comments, p.takeComments()...)
+
+	return block, nil
 }
 
-func (p *parser) ParseExpression() (ast.Expression, error) {
-	return p.parseBlock()
+// parseLoopBody parses the braced body of a loop, in which break and continue
+// are allowed.
+func (p *Parser) parseLoopBody() (ast.Expression, error) {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	return p.parseBraces()
 }
 
-func (p *parser) parseBlock() (ast.Expression, error) {
+// parseStatements parses statements up to the given token type, which is not
+// consumed, or the end of the input. Errors are reported, and parsing resumes
+// with the next statement. The comments after a statement on the same line
+// are attached to it, and those before the end to the last statement. Without
+// statements, they are returned instead.
+func (p *Parser) parseStatements(end lexer.TokenType) ([]ast.Expression, []lexer.Trivia) {
 	var statements []ast.Expression
 
-	for p.lookAhead(0).Type != lexer.TypeEOF {
-		statement, err := p.parseExpression(0)
+	for t := p.LookAhead(0).Type; t != end && t != lexer.TypeEOF; t = p.LookAhead(0).Type {
+		statement, err := p.Parse(0)
 		if err != nil {
-			return nil, err
+			p.report(err)
+			p.synchronize()
+
+			continue
 		}
 
 		statements = append(statements, statement)
 
-		if p.lookAhead(0).Type == lexer.TypeSemi {
-			p.consume()
+		if p.LookAhead(0).Type == lexer.TypeSemi {
+			p.Consume()
 		}
+
+		statement.Comments().Trailing = append(statement.Comments().Trailing, p.takeComments()...)
 	}
 
-	return ast.BlockExpression(statements), nil
+	comments := append(p.takeComments(), p.takeLeading()...)
+
+	if len(statements) > 0 {
+		last := statements[len(statements)-1].Comments()
+		last.Trailing = append(last.Trailing, comments...)
+
+		return statements, nil
+	}
+
+	return nil, comments
 }
 
-func (p *parser) parseExpression(precedence Precedence) (ast.Expression, error) {
-	t := p.consume()
+func (p *Parser) Parse(precedence Precedence) (ast.Expression, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	// The comments before the expression belong to it.
+	leading := append(p.takeComments(), p.takeLeading()...)
+
+	t := p.LookAhead(0)
 
 	if prefix, ok := p.prefixParselets[t.Type]; ok {
+		p.Consume()
+
 		left, err := prefix.Parse(p, t)
 		if err != nil {
 			return nil, err
 		}
 
 		for precedence < p.getPrecedence() {
-			t = p.consume()
+			// The comments before an infix operator belong to its left
+			// operand.
+			comments := left.Comments()
+			comments.Trailing = append(comments.Trailing, p.takeComments()...)
+			comments.Trailing = append(comments.Trailing, p.takeLeading()...)
+
+			t = p.Consume()
 
-			if infix, ok := p.infixParselets[t.Type]; ok {
+			if infix, ok := p.infix(t); ok {
 				left, err = infix.Parse(p, left, t)
 				if err != nil {
 					return nil, err
@@ -106,38 +227,254 @@ func (p *parser) parseExpression(precedence Precedence) (ast.Expression, error)
 			}
 		}
 
+		left.Comments().Leading = append(leading, left.Comments().Leading...)
+
+		if p.last == nil {
+			p.last = left
+		}
+
 		return left, nil
 	}
 
-	return nil, fmt.Errorf("Unexpected token: " + t.Text)
+	return nil, p.unexpected(t)
+}
+
+// report records a parse error as a diagnostic.
+func (p *Parser) report(err error) {
+	var diag *Diagnostic
+
+	if !errors.As(err, &diag) {
+		diag = p.Errorf(p.LookAhead(0).Span, "%v", err)
+	}
+
+	p.diagnostics = append(p.diagnostics, diag)
 }
 
-func (p *parser) match(t lexer.TokenType) bool {
-	if p.lookAhead(0).Type != t {
+// synchronize skips tokens after an error until it reaches a point where
+// parsing can resume: after a ';' or ')' (and an optional ';' following it),
+// before the '}' that closes the current block, or at the end of the input.
+// Brackets opened while skipping are skipped as a whole, and a ')' followed by
+// "=>" closes a lambda's parameter list, so its body is skipped too.
+func (p *Parser) synchronize() {
+	depth := 0
+
+	for {
+		switch p.LookAhead(0).Type {
+		case lexer.TypeEOF:
+			return
+		case lexer.TypeLParen, lexer.TypeLBracket, lexer.TypeLBrace:
+			depth++
+
+			p.Consume()
+		case lexer.TypeRBracket:
+			if depth > 0 {
+				depth--
+			}
+
+			p.Consume()
+		case lexer.TypeRBrace:
+			if depth > 0 {
+				depth--
+			} else if p.braces > 0 {
+				return
+			}
+
+			p.Consume()
+		case lexer.TypeSemi:
+			p.Consume()
+
+			if depth == 0 {
+				return
+			}
+		case lexer.TypeRParen:
+			p.Consume()
+
+			if depth > 0 {
+				depth--
+
+				continue
+			}
+
+			if p.LookAhead(0).Type == lexer.TypeArrow {
+				continue
+			}
+
+			p.Match(lexer.TypeSemi)
+
+			return
+		default:
+			p.Consume()
+		}
+	}
+}
+
+// Errorf returns an error diagnostic for the given span.
+func (p *Parser) Errorf(span lexer.Span, format string, args ...any) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Span:     span,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// unexpected returns an error diagnostic for an unexpected token, optionally
+// listing the token types that would have been accepted instead. Illegal tokens
+// are reported using the lexer's error.
+func (p *Parser) unexpected(found lexer.Token, expected ...lexer.TokenType) *Diagnostic {
+	var diag *Diagnostic
+
+	if found.Type == lexer.TypeIllegal {
+		diag = p.Errorf(found.Span, "%v", found.Err)
+	} else if len(expected) == 0 {
+		diag = p.Errorf(found.Span, "unexpected %s", describe(found))
+	} else {
+		names := make([]string, 0, len(expected))
+
+		for _, t := range expected {
+			names = append(names, fmt.Sprintf("%q", t.String()))
+		}
+
+		diag = p.Errorf(found.Span, "expected %s but found %s", strings.Join(names, " or "), describe(found))
+	}
+
+	diag.Expected = expected
+	diag.Found = found
+
+	return diag
+}
+
+// isLambda reports whether the tokens following a '(' are a parameter list,
+// i.e. whether the matching ')' is followed by "=>". Defaults can contain
+// brackets and blocks, so only a ';' outside of them ends the search.
+func (p *Parser) isLambda() bool {
+	depth := 0
+
+	for i := 0; ; i++ {
+		switch p.LookAhead(i).Type {
+		case lexer.TypeLParen, lexer.TypeLBracket, lexer.TypeLBrace:
+			depth++
+		case lexer.TypeRParen:
+			if depth == 0 {
+				return p.LookAhead(i+1).Type == lexer.TypeArrow
+			}
+
+			depth--
+		case lexer.TypeRBracket, lexer.TypeRBrace:
+			if depth == 0 {
+				return false
+			}
+
+			depth--
+		case lexer.TypeSemi:
+			if depth == 0 {
+				return false
+			}
+		case lexer.TypeEOF:
+			return false
+		}
+	}
+}
+
+// operator consumes the spelling of an operator that isn't known to the lexer
+// yet, which is lexed as a run of adjacent symbol tokens.
+func (p *Parser) operator() (string, lexer.Span, error) {
+	first := p.LookAhead(0)
+	if !lexer.IsOperator(first.Text) {
+		return "", first.Span, p.Errorf(first.Span, "expected an operator but found %s", describe(first))
+	}
+
+	last := p.Consume()
+	text := last.Text
+
+	for {
+		next := p.LookAhead(0)
+
+		adjacent := len(last.Trailing) == 0 && len(next.Leading) == 0 && next.Span.Start == last.Span.End
+		if !adjacent || next.Type == lexer.TypeEOF || !lexer.IsOperator(next.Text) {
+			return text, first.Span.To(last.Span), nil
+		}
+
+		last = p.Consume()
+		text += last.Text
+	}
+}
+
+func (p *Parser) Match(t lexer.TokenType) bool {
+	if p.LookAhead(0).Type != t {
 		return false
 	}
 
-	p.consume()
+	p.Consume()
 
 	return true
 }
 
-func (p *parser) expect(t lexer.TokenType) {
-	if !p.match(t) {
-		panic("Expected token " + string(t) + " but got " + p.lookAhead(0).Text)
+func (p *Parser) Expect(t lexer.TokenType) (lexer.Token, error) {
+	if found := p.LookAhead(0); found.Type != t {
+		return found, p.unexpected(found, t)
 	}
+
+	return p.Consume(), nil
 }
 
-func (p *parser) consume() lexer.Token {
-	p.lookAhead(0)
+func (p *Parser) Consume() lexer.Token {
+	p.LookAhead(0)
+
+	p.comments = append(p.comments, p.takeLeading()...)
 
 	result := p.read[0]
 	p.read = p.read[1:]
 
+	switch result.Type {
+	case lexer.TypeRParen, lexer.TypeRBracket, lexer.TypeRBrace:
+		if p.last != nil {
+			comments := p.last.Comments()
+			comments.Trailing = append(comments.Trailing, p.takeComments()...)
+		}
+	}
+
+	p.last = nil
+	p.comments = append(p.comments, commentTrivia(result.Trailing)...)
+
 	return result
 }
 
-func (p *parser) lookAhead(distance int) lexer.Token {
+// takeComments returns the comments of the consumed tokens that aren't
+// attached to a node yet.
+func (p *Parser) takeComments() []lexer.Trivia {
+	result := p.comments
+	p.comments = nil
+
+	return result
+}
+
+// takeLeading returns the comments before the next token, unless they were
+// taken already.
+func (p *Parser) takeLeading() []lexer.Trivia {
+	next := p.LookAhead(0)
+	if next.Span.Start.Offset == p.taken {
+		return nil
+	}
+
+	p.taken = next.Span.Start.Offset
+
+	return commentTrivia(next.Leading)
+}
+
+// commentTrivia returns the comments among the trivia.
+func commentTrivia(trivia []lexer.Trivia) []lexer.Trivia {
+	var result []lexer.Trivia
+
+	for _, t := range trivia {
+		if t.Kind == lexer.TriviaLineComment || t.Kind == lexer.TriviaBlockComment {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+func (p *Parser) LookAhead(distance int) lexer.Token {
 	for distance >= len(p.read) {
 		p.read = append(p.read, p.tokens.Next())
 	}
@@ -145,10 +482,24 @@ func (p *parser) lookAhead(distance int) lexer.Token {
 	return p.read[distance]
 }
 
-func (p *parser) getPrecedence() Precedence {
-	if parselet, ok := p.infixParselets[p.lookAhead(0).Type]; ok {
+func (p *Parser) getPrecedence() Precedence {
+	if parselet, ok := p.infix(p.LookAhead(0)); ok {
 		return parselet.Precedence()
 	}
 
 	return PrecUnknown
 }
+
+// infix returns the infix parselet for a token. Operators declared in the
+// input are looked up by their spelling.
+func (p *Parser) infix(t lexer.Token) (InfixParselet, bool) {
+	if t.Type == lexer.TypeOperator {
+		parselet, ok := p.operators[t.Text]
+
+		return parselet, ok
+	}
+
+	parselet, ok := p.infixParselets[t.Type]
+
+	return parselet, ok
+}
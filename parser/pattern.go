@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"github.com/corani/bantamgo/ast"
+)
+
+// pattern converts the left-hand side of a destructuring assignment, which was
+// parsed as an expression, to a pattern. Each name may only appear once, and
+// the comments of the expression are kept on the pattern.
+func (p *Parser) pattern(expr ast.Expression, names map[string]bool) (ast.Pattern, error) {
+	result, err := p.convert(expr, names)
+	if err != nil {
+		return nil, err
+	}
+
+	*result.Comments() = *expr.Comments()
+
+	return result, nil
+}
+
+func (p *Parser) convert(expr ast.Expression, names map[string]bool) (ast.Pattern, error) {
+	switch expr := expr.(type) {
+	case *ast.NameExpressionNode:
+		if names[expr.Name] {
+			return nil, p.Errorf(expr.Span(), "duplicate name %q in pattern", expr.Name)
+		}
+
+		names[expr.Name] = true
+
+		return ast.NamePattern(expr.Span(), expr.Name), nil
+	case *ast.TupleExpressionNode:
+		elements, err := p.patterns(expr.Elements, names)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.TuplePattern(expr.Span(), elements), nil
+	case *ast.ListExpressionNode:
+		elements, err := p.patterns(expr.Elements, names)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.ListPattern(expr.Span(), elements), nil
+	case *ast.RecordExpressionNode:
+		fields := make([]ast.FieldPattern, 0, len(expr.Fields))
+
+		for _, field := range expr.Fields {
+			pattern, err := p.pattern(field.Value, names)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, ast.FieldPattern{Name: field.Name, Pattern: pattern})
+		}
+
+		return ast.RecordPattern(expr.Span(), fields), nil
+	default:
+		return nil, p.Errorf(expr.Span(), "expected a name or a pattern")
+	}
+}
+
+func (p *Parser) patterns(exprs []ast.Expression, names map[string]bool) ([]ast.Pattern, error) {
+	result := make([]ast.Pattern, 0, len(exprs))
+
+	for _, expr := range exprs {
+		pattern, err := p.pattern(expr, names)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, pattern)
+	}
+
+	return result, nil
+}
@@ -5,15 +5,36 @@ type Precedence int
 const (
 	PrecUnknown     Precedence = 0
 	PrecAssignment  Precedence = 1
-	PrecConditional Precedence = 2
-	PrecSum         Precedence = 3
-	PrecProduct     Precedence = 4
-	PrecExponent    Precedence = 5
-	PrecPrefix      Precedence = 6
-	PrecPostfix     Precedence = 7
-	PrecCall        Precedence = 8
+	PrecPipeline    Precedence = 2
+	PrecConditional Precedence = 3
+	PrecLogicalOr   Precedence = 4
+	PrecLogicalAnd  Precedence = 5
+	PrecComparison  Precedence = 6
+	PrecSum         Precedence = 7
+	PrecProduct     Precedence = 8
+	PrecExponent    Precedence = 9
+	PrecPrefix      Precedence = 10
+	PrecPostfix     Precedence = 11
+	PrecCall        Precedence = 12
 )
 
+// scriptPrecedences maps the precedences used in operator declarations, which
+// count from 1, to the levels above. Scripts depend on these numbers, so levels
+// that are added later, like PrecPipeline, are left out.
+var scriptPrecedences = []Precedence{
+	PrecAssignment,
+	PrecConditional,
+	PrecLogicalOr,
+	PrecLogicalAnd,
+	PrecComparison,
+	PrecSum,
+	PrecProduct,
+	PrecExponent,
+	PrecPrefix,
+	PrecPostfix,
+	PrecCall,
+}
+
 type Associativity bool
 
 const (
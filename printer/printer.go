@@ -18,16 +18,55 @@ func (p *printer) String() string {
 
 type printer struct {
 	sb *strings.Builder
+	// depth counts the enclosing blocks, only nested blocks have braces.
+	depth int
 }
 
 func (p *printer) VisitBlock(expressions []ast.Expression) {
+	nested := p.depth > 0
+
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if nested {
+		if len(expressions) == 0 {
+			p.sb.WriteString("{}")
+
+			return
+		}
+
+		p.sb.WriteString("{ ")
+	}
+
+	// Statements are printed with their trailing comments after the ';', so
+	// that they are attached to the same statement when parsed again.
 	for i, expr := range expressions {
-		if i > 0 {
+		comments := expr.Comments()
+
+		p.writeLeading(comments.Leading)
+		expr.Visit(p)
+
+		if i < len(expressions)-1 {
 			p.sb.WriteByte(';')
+		}
+
+		p.writeTrailing(comments.Trailing)
+
+		if i == len(expressions)-1 || strings.HasSuffix(p.sb.String(), "\n") {
+			continue
+		}
+
+		// A comment on the same line as the ';' would belong to the
+		// previous statement.
+		if len(expressions[i+1].Comments().Leading) > 0 {
+			p.sb.WriteByte('\n')
+		} else {
 			p.sb.WriteByte(' ')
 		}
+	}
 
-		expr.Visit(p)
+	if nested {
+		p.sb.WriteString(" }")
 	}
 }
 
@@ -35,60 +74,335 @@ func (p *printer) VisitName(name string) {
 	p.sb.WriteString(name)
 }
 
-func (p *printer) VisitNumber(value float64) {
-	p.sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+func (p *printer) VisitNumber(text string, _ float64) {
+	p.sb.WriteString(text)
+}
+
+func (p *printer) VisitInteger(text string, _ int64) {
+	p.sb.WriteString(text)
+}
+
+func (p *printer) VisitString(value string) {
+	p.sb.WriteString(lexer.Quote(value))
 }
 
 func (p *printer) VisitAssign(name string, right ast.Expression) {
 	p.sb.WriteString("(")
 	p.sb.WriteString(name)
 	p.sb.WriteString(" = ")
-	right.Visit(p)
+	p.visit(right)
 	p.sb.WriteString(")")
 }
 
 func (p *printer) VisitConditional(condition, thenBranch, elseBranch ast.Expression) {
 	p.sb.WriteString("(")
-	condition.Visit(p)
+	p.visit(condition)
 	p.sb.WriteString(" ? ")
-	thenBranch.Visit(p)
+	p.visit(thenBranch)
 	p.sb.WriteString(" : ")
-	elseBranch.Visit(p)
+	p.visit(elseBranch)
 	p.sb.WriteString(")")
 }
 
-func (p *printer) VisitCall(callee ast.Expression, arguments []ast.Expression) {
-	callee.Visit(p)
+func (p *printer) VisitCall(callee ast.Expression, arguments []ast.Expression, named []ast.NamedArgument) {
+	p.visit(callee)
 	p.sb.WriteString("(")
 	for i, arg := range arguments {
 		if i > 0 {
 			p.sb.WriteString(", ")
 		}
-		arg.Visit(p)
+		p.visit(arg)
+	}
+	for i, arg := range named {
+		if i > 0 || len(arguments) > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.sb.WriteString(arg.Name)
+		p.sb.WriteString(": ")
+		p.visit(arg.Value)
 	}
 	p.sb.WriteString(")")
 }
 
 func (p *printer) VisitPrefix(operator lexer.TokenType, right ast.Expression) {
 	p.sb.WriteString("(")
-	p.sb.WriteString(string(operator))
-	right.Visit(p)
+	p.sb.WriteString(operator.String())
+	p.visit(right)
 	p.sb.WriteString(")")
 }
 
 func (p *printer) VisitPostfix(left ast.Expression, operator lexer.TokenType) {
 	p.sb.WriteString("(")
-	left.Visit(p)
-	p.sb.WriteString(string(operator))
+	p.visit(left)
+	p.sb.WriteString(operator.String())
 	p.sb.WriteString(")")
 }
 
 func (p *printer) VisitInfix(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	p.writeInfix(left, operator.String(), right)
+}
+
+func (p *printer) VisitScriptInfix(left ast.Expression, operator string, right ast.Expression) {
+	p.writeInfix(left, operator, right)
+}
+
+func (p *printer) writeInfix(left ast.Expression, operator string, right ast.Expression) {
 	p.sb.WriteString("(")
-	left.Visit(p)
+	p.visit(left)
+	p.sb.WriteString(" ")
+	p.sb.WriteString(operator)
+	p.sb.WriteString(" ")
+	p.visit(right)
+	p.sb.WriteString(")")
+}
+
+func (p *printer) VisitLogical(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	p.VisitInfix(left, operator, right)
+}
+
+func (p *printer) VisitLambda(params []ast.Parameter, body ast.Expression) {
+	p.sb.WriteString("((")
+	for i, param := range params {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.sb.WriteString(param.Name)
+		if param.Default != nil {
+			p.sb.WriteString(" = ")
+			p.visit(param.Default)
+		}
+	}
+	p.sb.WriteString(") => ")
+	p.visit(body)
+	p.sb.WriteString(")")
+}
+
+// VisitOperator prints a declaration without parentheses, because declarations
+// are only allowed as top-level statements.
+func (p *printer) VisitOperator(operator string, precedence int, rightAssoc bool, function ast.Expression) {
+	p.sb.WriteString(fixity(rightAssoc))
+	p.sb.WriteString(" ")
+	p.sb.WriteString(strconv.Itoa(precedence))
+	p.sb.WriteString(" ")
+	p.sb.WriteString(operator)
+	p.sb.WriteString(" = ")
+	p.visit(function)
+}
+
+// fixity returns the keyword that declares an operator with the given
+// associativity.
+func fixity(rightAssoc bool) string {
+	if rightAssoc {
+		return lexer.TypeInfixr.String()
+	}
+
+	return lexer.TypeInfixl.String()
+}
+
+func (p *printer) VisitIf(condition, thenBranch, elseBranch ast.Expression) {
+	p.sb.WriteString("if ")
+	p.visit(condition)
+	p.sb.WriteString(" ")
+	p.visit(thenBranch)
+
+	if elseBranch != nil {
+		p.sb.WriteString(" else ")
+		p.visit(elseBranch)
+	}
+}
+
+func (p *printer) VisitWhile(condition, body ast.Expression) {
+	p.sb.WriteString("while ")
+	p.visit(condition)
 	p.sb.WriteString(" ")
-	p.sb.WriteString(string(operator))
+	p.visit(body)
+}
+
+func (p *printer) VisitFor(name string, start, end, body ast.Expression) {
+	p.sb.WriteString("for ")
+	p.sb.WriteString(name)
+	p.sb.WriteString(" in ")
+	p.visit(start)
+	p.sb.WriteString("..")
+	p.visit(end)
 	p.sb.WriteString(" ")
-	right.Visit(p)
+	p.visit(body)
+}
+
+func (p *printer) VisitBreak() {
+	p.sb.WriteString("break")
+}
+
+func (p *printer) VisitContinue() {
+	p.sb.WriteString("continue")
+}
+
+func (p *printer) VisitList(elements []ast.Expression) {
+	p.sb.WriteString("[")
+	for i, element := range elements {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.visit(element)
+	}
+	p.sb.WriteString("]")
+}
+
+func (p *printer) VisitIndex(target, index ast.Expression) {
+	p.visit(target)
+	p.sb.WriteString("[")
+	p.visit(index)
+	p.sb.WriteString("]")
+}
+
+func (p *printer) VisitSlice(target, start, end ast.Expression) {
+	p.visit(target)
+	p.sb.WriteString("[")
+	if start != nil {
+		p.visit(start)
+	}
+	p.sb.WriteString(":")
+	if end != nil {
+		p.visit(end)
+	}
+	p.sb.WriteString("]")
+}
+
+// VisitRecord prints the empty record as "{:}", as "{}" is an empty block.
+func (p *printer) VisitRecord(fields []ast.Field) {
+	if len(fields) == 0 {
+		p.sb.WriteString("{:}")
+
+		return
+	}
+
+	p.sb.WriteString("{")
+	for i, field := range fields {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.sb.WriteString(field.Name)
+		p.sb.WriteString(": ")
+		p.visit(field.Value)
+	}
+	p.sb.WriteString("}")
+}
+
+func (p *printer) VisitMember(target ast.Expression, name string) {
+	p.visit(target)
+	p.sb.WriteString(".")
+	p.sb.WriteString(name)
+}
+
+func (p *printer) VisitTuple(elements []ast.Expression) {
+	p.sb.WriteString("(")
+	for i, element := range elements {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.visit(element)
+	}
+	p.sb.WriteString(")")
+}
+
+func (p *printer) VisitDestructure(pattern ast.Pattern, right ast.Expression) {
+	p.sb.WriteString("(")
+	p.visitPattern(pattern)
+	p.sb.WriteString(" = ")
+	p.visit(right)
+	p.sb.WriteString(")")
+}
+
+func (p *printer) VisitNamePattern(name string) {
+	p.sb.WriteString(name)
+}
+
+func (p *printer) VisitTuplePattern(elements []ast.Pattern) {
+	p.sb.WriteString("(")
+	p.writePatterns(elements)
 	p.sb.WriteString(")")
 }
+
+func (p *printer) VisitListPattern(elements []ast.Pattern) {
+	p.sb.WriteString("[")
+	p.writePatterns(elements)
+	p.sb.WriteString("]")
+}
+
+// VisitRecordPattern prints fields that bind to their own name without the
+// name, like "{qty}".
+func (p *printer) VisitRecordPattern(fields []ast.FieldPattern) {
+	if len(fields) == 0 {
+		p.sb.WriteString("{:}")
+
+		return
+	}
+
+	p.sb.WriteString("{")
+	for i, field := range fields {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.sb.WriteString(field.Name)
+		if name, ok := field.Pattern.(*ast.NamePatternNode); !ok || name.Name != field.Name || hasComments(name) {
+			p.sb.WriteString(": ")
+			p.visitPattern(field.Pattern)
+		}
+	}
+	p.sb.WriteString("}")
+}
+
+func (p *printer) writePatterns(patterns []ast.Pattern) {
+	for i, pattern := range patterns {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		p.visitPattern(pattern)
+	}
+}
+
+// visit prints an expression with its comments.
+func (p *printer) visit(expr ast.Expression) {
+	p.writeLeading(expr.Comments().Leading)
+	expr.Visit(p)
+	p.writeTrailing(expr.Comments().Trailing)
+}
+
+// visitPattern prints a pattern with its comments.
+func (p *printer) visitPattern(pattern ast.Pattern) {
+	p.writeLeading(pattern.Comments().Leading)
+	pattern.Visit(p)
+	p.writeTrailing(pattern.Comments().Trailing)
+}
+
+// writeLeading prints the comments before a node. A line comment ends the
+// line, so the node isn't commented out.
+func (p *printer) writeLeading(comments []lexer.Trivia) {
+	for _, comment := range comments {
+		p.sb.WriteString(comment.Text)
+
+		if comment.Kind == lexer.TriviaLineComment {
+			p.sb.WriteByte('\n')
+		} else {
+			p.sb.WriteByte(' ')
+		}
+	}
+}
+
+// writeTrailing prints the comments after a node.
+func (p *printer) writeTrailing(comments []lexer.Trivia) {
+	for _, comment := range comments {
+		p.sb.WriteByte(' ')
+		p.sb.WriteString(comment.Text)
+
+		if comment.Kind == lexer.TriviaLineComment {
+			p.sb.WriteByte('\n')
+		}
+	}
+}
+
+func hasComments(node ast.Node) bool {
+	comments := node.Comments()
+
+	return len(comments.Leading) > 0 || len(comments.Trailing) > 0
+}
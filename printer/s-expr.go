@@ -35,9 +35,21 @@ func (s *sExpr) VisitName(name string) {
 	s.sb.WriteString("')")
 }
 
-func (s *sExpr) VisitNumber(value float64) {
+func (s *sExpr) VisitNumber(text string, _ float64) {
 	s.sb.WriteString("(number ")
-	s.sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	s.sb.WriteString(text)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitInteger(text string, _ int64) {
+	s.sb.WriteString("(number ")
+	s.sb.WriteString(text)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitString(value string) {
+	s.sb.WriteString("(string ")
+	s.sb.WriteString(lexer.Quote(value))
 	s.sb.WriteString(")")
 }
 
@@ -59,7 +71,7 @@ func (s *sExpr) VisitConditional(condition, thenBranch, elseBranch ast.Expressio
 	s.sb.WriteString(")")
 }
 
-func (s *sExpr) VisitCall(callee ast.Expression, arguments []ast.Expression) {
+func (s *sExpr) VisitCall(callee ast.Expression, arguments []ast.Expression, named []ast.NamedArgument) {
 	s.sb.WriteString("(call ")
 	callee.Visit(s)
 	s.sb.WriteString(" ")
@@ -67,12 +79,19 @@ func (s *sExpr) VisitCall(callee ast.Expression, arguments []ast.Expression) {
 		arg.Visit(s)
 		s.sb.WriteString(" ")
 	}
+	for _, arg := range named {
+		s.sb.WriteString("(named '")
+		s.sb.WriteString(arg.Name)
+		s.sb.WriteString("' ")
+		arg.Value.Visit(s)
+		s.sb.WriteString(") ")
+	}
 	s.sb.WriteString(")")
 }
 
 func (s *sExpr) VisitPrefix(operator lexer.TokenType, right ast.Expression) {
 	s.sb.WriteString("(prefix")
-	s.sb.WriteString(string(operator))
+	s.sb.WriteString(operator.String())
 	s.sb.WriteString(" ")
 	right.Visit(s)
 	s.sb.WriteString(")")
@@ -80,18 +99,209 @@ func (s *sExpr) VisitPrefix(operator lexer.TokenType, right ast.Expression) {
 
 func (s *sExpr) VisitPostfix(left ast.Expression, operator lexer.TokenType) {
 	s.sb.WriteString("(postfix")
-	s.sb.WriteString(string(operator))
+	s.sb.WriteString(operator.String())
 	s.sb.WriteString(" ")
 	left.Visit(s)
 	s.sb.WriteString(")")
 }
 
 func (s *sExpr) VisitInfix(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	s.writeInfix(left, operator.String(), right)
+}
+
+func (s *sExpr) VisitScriptInfix(left ast.Expression, operator string, right ast.Expression) {
+	s.writeInfix(left, operator, right)
+}
+
+func (s *sExpr) writeInfix(left ast.Expression, operator string, right ast.Expression) {
 	s.sb.WriteString("(")
-	s.sb.WriteString(string(operator))
+	s.sb.WriteString(operator)
 	s.sb.WriteString(" ")
 	left.Visit(s)
 	s.sb.WriteString(" ")
 	right.Visit(s)
 	s.sb.WriteString(")")
 }
+
+func (s *sExpr) VisitLogical(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	s.VisitInfix(left, operator, right)
+}
+
+func (s *sExpr) VisitLambda(params []ast.Parameter, body ast.Expression) {
+	s.sb.WriteString("(lambda (")
+	for i, param := range params {
+		if i > 0 {
+			s.sb.WriteString(" ")
+		}
+		if param.Default == nil {
+			s.sb.WriteString(param.Name)
+			continue
+		}
+		s.sb.WriteString("(default ")
+		s.sb.WriteString(param.Name)
+		s.sb.WriteString(" ")
+		param.Default.Visit(s)
+		s.sb.WriteString(")")
+	}
+	s.sb.WriteString(") ")
+	body.Visit(s)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitOperator(operator string, precedence int, rightAssoc bool, function ast.Expression) {
+	s.sb.WriteString("(")
+	s.sb.WriteString(fixity(rightAssoc))
+	s.sb.WriteString(" ")
+	s.sb.WriteString(strconv.Itoa(precedence))
+	s.sb.WriteString(" '")
+	s.sb.WriteString(operator)
+	s.sb.WriteString("' ")
+	function.Visit(s)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitIf(condition, thenBranch, elseBranch ast.Expression) {
+	s.sb.WriteString("(if ")
+	condition.Visit(s)
+	s.sb.WriteString(" ")
+	thenBranch.Visit(s)
+
+	if elseBranch != nil {
+		s.sb.WriteString(" ")
+		elseBranch.Visit(s)
+	}
+
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitWhile(condition, body ast.Expression) {
+	s.sb.WriteString("(while ")
+	condition.Visit(s)
+	s.sb.WriteString(" ")
+	body.Visit(s)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitFor(name string, start, end, body ast.Expression) {
+	s.sb.WriteString("(for '")
+	s.sb.WriteString(name)
+	s.sb.WriteString("' ")
+	start.Visit(s)
+	s.sb.WriteString(" ")
+	end.Visit(s)
+	s.sb.WriteString(" ")
+	body.Visit(s)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitBreak() {
+	s.sb.WriteString("(break)")
+}
+
+func (s *sExpr) VisitContinue() {
+	s.sb.WriteString("(continue)")
+}
+
+func (s *sExpr) VisitList(elements []ast.Expression) {
+	s.sb.WriteString("(list ")
+	for _, element := range elements {
+		element.Visit(s)
+		s.sb.WriteString(" ")
+	}
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitIndex(target, index ast.Expression) {
+	s.sb.WriteString("(index ")
+	target.Visit(s)
+	s.sb.WriteString(" ")
+	index.Visit(s)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitSlice(target, start, end ast.Expression) {
+	s.sb.WriteString("(slice ")
+	target.Visit(s)
+	for _, bound := range []ast.Expression{start, end} {
+		s.sb.WriteString(" ")
+		if bound != nil {
+			bound.Visit(s)
+		} else {
+			s.sb.WriteString("nil")
+		}
+	}
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitRecord(fields []ast.Field) {
+	s.sb.WriteString("(record ")
+	for _, field := range fields {
+		s.sb.WriteString("(field '")
+		s.sb.WriteString(field.Name)
+		s.sb.WriteString("' ")
+		field.Value.Visit(s)
+		s.sb.WriteString(") ")
+	}
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitMember(target ast.Expression, name string) {
+	s.sb.WriteString("(member ")
+	target.Visit(s)
+	s.sb.WriteString(" '")
+	s.sb.WriteString(name)
+	s.sb.WriteString("')")
+}
+
+func (s *sExpr) VisitTuple(elements []ast.Expression) {
+	s.sb.WriteString("(tuple ")
+	for _, element := range elements {
+		element.Visit(s)
+		s.sb.WriteString(" ")
+	}
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitDestructure(pattern ast.Pattern, right ast.Expression) {
+	s.sb.WriteString("(destructure ")
+	pattern.Visit(s)
+	s.sb.WriteString(" ")
+	right.Visit(s)
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitNamePattern(name string) {
+	s.sb.WriteString("'")
+	s.sb.WriteString(name)
+	s.sb.WriteString("'")
+}
+
+func (s *sExpr) VisitTuplePattern(elements []ast.Pattern) {
+	s.sb.WriteString("(tuple ")
+	for _, element := range elements {
+		element.Visit(s)
+		s.sb.WriteString(" ")
+	}
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitListPattern(elements []ast.Pattern) {
+	s.sb.WriteString("(list ")
+	for _, element := range elements {
+		element.Visit(s)
+		s.sb.WriteString(" ")
+	}
+	s.sb.WriteString(")")
+}
+
+func (s *sExpr) VisitRecordPattern(fields []ast.FieldPattern) {
+	s.sb.WriteString("(record ")
+	for _, field := range fields {
+		s.sb.WriteString("(field '")
+		s.sb.WriteString(field.Name)
+		s.sb.WriteString("' ")
+		field.Pattern.Visit(s)
+		s.sb.WriteString(") ")
+	}
+	s.sb.WriteString(")")
+}
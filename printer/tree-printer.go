@@ -45,10 +45,24 @@ func (t *treePrinter) VisitName(name string) {
 	t.sb.WriteString("'\n")
 }
 
-func (t *treePrinter) VisitNumber(value float64) {
+func (t *treePrinter) VisitNumber(text string, _ float64) {
 	t.writeIndent()
 	t.sb.WriteString("number ")
-	t.sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	t.sb.WriteString(text)
+	t.sb.WriteString("\n")
+}
+
+func (t *treePrinter) VisitInteger(text string, _ int64) {
+	t.writeIndent()
+	t.sb.WriteString("number ")
+	t.sb.WriteString(text)
+	t.sb.WriteString("\n")
+}
+
+func (t *treePrinter) VisitString(value string) {
+	t.writeIndent()
+	t.sb.WriteString("string ")
+	t.sb.WriteString(lexer.Quote(value))
 	t.sb.WriteString("\n")
 }
 
@@ -74,7 +88,7 @@ func (t *treePrinter) VisitConditional(condition, thenBranch, elseBranch ast.Exp
 	t.indent--
 }
 
-func (t *treePrinter) VisitCall(callee ast.Expression, arguments []ast.Expression) {
+func (t *treePrinter) VisitCall(callee ast.Expression, arguments []ast.Expression, named []ast.NamedArgument) {
 	t.writeIndent()
 	t.sb.WriteString("call\n")
 	t.indent++
@@ -82,13 +96,22 @@ func (t *treePrinter) VisitCall(callee ast.Expression, arguments []ast.Expressio
 	for _, arg := range arguments {
 		arg.Visit(t)
 	}
+	for _, arg := range named {
+		t.writeIndent()
+		t.sb.WriteString("named '")
+		t.sb.WriteString(arg.Name)
+		t.sb.WriteString("'\n")
+		t.indent++
+		arg.Value.Visit(t)
+		t.indent--
+	}
 	t.indent--
 }
 
 func (t *treePrinter) VisitPrefix(operator lexer.TokenType, right ast.Expression) {
 	t.writeIndent()
 	t.sb.WriteString("prefix '")
-	t.sb.WriteString(string(operator))
+	t.sb.WriteString(operator.String())
 	t.sb.WriteString("'\n")
 	t.indent++
 	right.Visit(t)
@@ -98,7 +121,7 @@ func (t *treePrinter) VisitPrefix(operator lexer.TokenType, right ast.Expression
 func (t *treePrinter) VisitPostfix(left ast.Expression, operator lexer.TokenType) {
 	t.writeIndent()
 	t.sb.WriteString("postfix '")
-	t.sb.WriteString(string(operator))
+	t.sb.WriteString(operator.String())
 	t.sb.WriteString("'\n")
 	t.indent++
 	left.Visit(t)
@@ -106,12 +129,229 @@ func (t *treePrinter) VisitPostfix(left ast.Expression, operator lexer.TokenType
 }
 
 func (t *treePrinter) VisitInfix(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	t.writeInfix(left, operator.String(), right)
+}
+
+func (t *treePrinter) VisitScriptInfix(left ast.Expression, operator string, right ast.Expression) {
+	t.writeInfix(left, operator, right)
+}
+
+func (t *treePrinter) writeInfix(left ast.Expression, operator string, right ast.Expression) {
 	t.writeIndent()
 	t.sb.WriteString("infix '")
-	t.sb.WriteString(string(operator))
+	t.sb.WriteString(operator)
+	t.sb.WriteString("'\n")
+	t.indent++
+	left.Visit(t)
+	right.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitLogical(left ast.Expression, operator lexer.TokenType, right ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("logical '")
+	t.sb.WriteString(operator.String())
 	t.sb.WriteString("'\n")
 	t.indent++
 	left.Visit(t)
 	right.Visit(t)
 	t.indent--
 }
+
+func (t *treePrinter) VisitLambda(params []ast.Parameter, body ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("lambda\n")
+	t.indent++
+	for _, param := range params {
+		t.writeIndent()
+		t.sb.WriteString("param '")
+		t.sb.WriteString(param.Name)
+		t.sb.WriteString("'\n")
+		if param.Default != nil {
+			t.indent++
+			param.Default.Visit(t)
+			t.indent--
+		}
+	}
+	body.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitOperator(operator string, precedence int, rightAssoc bool, function ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString(fixity(rightAssoc))
+	t.sb.WriteString(" ")
+	t.sb.WriteString(strconv.Itoa(precedence))
+	t.sb.WriteString(" '")
+	t.sb.WriteString(operator)
+	t.sb.WriteString("'\n")
+	t.indent++
+	function.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitIf(condition, thenBranch, elseBranch ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("if\n")
+	t.indent++
+	condition.Visit(t)
+	thenBranch.Visit(t)
+	if elseBranch != nil {
+		elseBranch.Visit(t)
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitWhile(condition, body ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("while\n")
+	t.indent++
+	condition.Visit(t)
+	body.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitFor(name string, start, end, body ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("for '")
+	t.sb.WriteString(name)
+	t.sb.WriteString("'\n")
+	t.indent++
+	start.Visit(t)
+	end.Visit(t)
+	body.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitBreak() {
+	t.writeIndent()
+	t.sb.WriteString("break\n")
+}
+
+func (t *treePrinter) VisitContinue() {
+	t.writeIndent()
+	t.sb.WriteString("continue\n")
+}
+
+func (t *treePrinter) VisitList(elements []ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("list\n")
+	t.indent++
+	for _, element := range elements {
+		element.Visit(t)
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitIndex(target, index ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("index\n")
+	t.indent++
+	target.Visit(t)
+	index.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitSlice(target, start, end ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("slice\n")
+	t.indent++
+	target.Visit(t)
+	for _, bound := range []ast.Expression{start, end} {
+		if bound != nil {
+			bound.Visit(t)
+		} else {
+			t.writeIndent()
+			t.sb.WriteString("nil\n")
+		}
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitRecord(fields []ast.Field) {
+	t.writeIndent()
+	t.sb.WriteString("record\n")
+	t.indent++
+	for _, field := range fields {
+		t.writeIndent()
+		t.sb.WriteString("field '")
+		t.sb.WriteString(field.Name)
+		t.sb.WriteString("'\n")
+		t.indent++
+		field.Value.Visit(t)
+		t.indent--
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitMember(target ast.Expression, name string) {
+	t.writeIndent()
+	t.sb.WriteString("member '")
+	t.sb.WriteString(name)
+	t.sb.WriteString("'\n")
+	t.indent++
+	target.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitTuple(elements []ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("tuple\n")
+	t.indent++
+	for _, element := range elements {
+		element.Visit(t)
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitDestructure(pattern ast.Pattern, right ast.Expression) {
+	t.writeIndent()
+	t.sb.WriteString("destructure\n")
+	t.indent++
+	pattern.Visit(t)
+	right.Visit(t)
+	t.indent--
+}
+
+func (t *treePrinter) VisitNamePattern(name string) {
+	t.writeIndent()
+	t.sb.WriteString("name '")
+	t.sb.WriteString(name)
+	t.sb.WriteString("'\n")
+}
+
+func (t *treePrinter) VisitTuplePattern(elements []ast.Pattern) {
+	t.writeIndent()
+	t.sb.WriteString("tuple pattern\n")
+	t.indent++
+	for _, element := range elements {
+		element.Visit(t)
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitListPattern(elements []ast.Pattern) {
+	t.writeIndent()
+	t.sb.WriteString("list pattern\n")
+	t.indent++
+	for _, element := range elements {
+		element.Visit(t)
+	}
+	t.indent--
+}
+
+func (t *treePrinter) VisitRecordPattern(fields []ast.FieldPattern) {
+	t.writeIndent()
+	t.sb.WriteString("record pattern\n")
+	t.indent++
+	for _, field := range fields {
+		t.writeIndent()
+		t.sb.WriteString("field '")
+		t.sb.WriteString(field.Name)
+		t.sb.WriteString("'\n")
+		t.indent++
+		field.Pattern.Visit(t)
+		t.indent--
+	}
+	t.indent--
+}